@@ -1,12 +1,17 @@
 package apiserver
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
 	_ "github.com/lib/pq"
 
 	"github.com/gorilla/sessions"
 	"github.com/jmoiron/sqlx"
+	"github.com/zlyaptica/http-rest-api/internal/app/blob"
+	"github.com/zlyaptica/http-rest-api/internal/app/blob/localblob"
+	"github.com/zlyaptica/http-rest-api/internal/app/blob/s3blob"
 	"github.com/zlyaptica/http-rest-api/internal/app/store/sqlstore"
 )
 
@@ -19,8 +24,23 @@ func Start(config *Config) error {
 
 	defer db.Close()
 	store := sqlstore.New(db)
-	sessionStore := sessions.NewCookieStore([]byte(config.SessionKey))
-	srv := newServer(store, sessionStore)
+	sessionStore, err := newSessionStore(config)
+	if err != nil {
+		return err
+	}
+	blobs, err := newBlobStorage(config)
+	if err != nil {
+		return err
+	}
+
+	srv := newServer(store, sessionStore, blobs, config)
+	if err := configureEvents(db, config, srv); err != nil {
+		return err
+	}
+	if err := configureRateLimit(db, config, srv); err != nil {
+		return err
+	}
+	srv.startJobs()
 
 	return http.ListenAndServe(config.BindAddr, srv)
 }
@@ -33,3 +53,82 @@ func newDB(dbURL string) (*sqlx.DB, error) {
 
 	return db, nil
 }
+
+func newSessionStore(config *Config) (*sessions.CookieStore, error) {
+	store := sessions.NewCookieStore([]byte(config.SessionKey))
+	store.Options.HttpOnly = config.SessionHTTPOnly
+	store.Options.Secure = config.SessionSecure
+
+	switch strings.ToLower(config.SessionSameSite) {
+	case "", "lax":
+		store.Options.SameSite = http.SameSiteLaxMode
+	case "strict":
+		store.Options.SameSite = http.SameSiteStrictMode
+	case "none":
+		if !config.SessionSecure {
+			return nil, fmt.Errorf("session_same_site = \"none\" requires session_secure")
+		}
+		store.Options.SameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("unknown session_same_site %q", config.SessionSameSite)
+	}
+
+	return store, nil
+}
+
+func newBlobStorage(config *Config) (blob.Storage, error) {
+	switch config.BlobBackend {
+	case "", "local":
+		return localblob.New(config.BlobDir), nil
+	case "s3":
+		return s3blob.New(s3blob.Config{
+			Endpoint:  config.S3Endpoint,
+			Region:    config.S3Region,
+			Bucket:    config.S3Bucket,
+			AccessKey: config.S3AccessKey,
+			SecretKey: config.S3SecretKey,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown blob backend %q", config.BlobBackend)
+	}
+}
+
+// configureEvents relays real-time events through Postgres when several instances share the database.
+func configureEvents(db *sqlx.DB, config *Config, srv *server) error {
+	switch config.EventsBackend {
+	case "", "memory":
+		return nil
+	case "postgres":
+		relay, err := newPGRelay(db, config.DatabaseURL, srv.hub, srv.logger)
+		if err != nil {
+			return err
+		}
+
+		srv.hub.relay = relay.publish
+		go relay.run()
+		return nil
+	default:
+		return fmt.Errorf("unknown events backend %q", config.EventsBackend)
+	}
+}
+
+// configureRateLimit keeps rate limit buckets in Postgres when several instances share the database.
+func configureRateLimit(db *sqlx.DB, config *Config, srv *server) error {
+	var backend rateLimitBackend
+	switch config.RateLimitBackend {
+	case "", "memory":
+		backend = newMemoryRateLimitBackend()
+	case "postgres":
+		backend = &pgRateLimitBackend{db: db}
+	default:
+		return fmt.Errorf("unknown rate limit backend %q", config.RateLimitBackend)
+	}
+
+	limiter, err := newRateLimiter(config, backend)
+	if err != nil {
+		return err
+	}
+
+	srv.rateLimiter = limiter
+	return nil
+}
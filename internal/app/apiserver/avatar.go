@@ -0,0 +1,68 @@
+package apiserver
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/zlyaptica/http-rest-api/internal/app/blob"
+	"github.com/zlyaptica/http-rest-api/internal/app/imaging"
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+// avatarSizes are the square sizes every uploaded avatar is rendered to.
+var avatarSizes = []int{64, 128, 256}
+
+func avatarKey(base string, size int) string {
+	return fmt.Sprintf("%s-%d.png", base, size)
+}
+
+func isAvatarSize(size int) bool {
+	for _, s := range avatarSizes {
+		if s == size {
+			return true
+		}
+	}
+
+	return false
+}
+
+// storeAvatar renders img to every avatar size and returns the base key they were stored under.
+func storeAvatar(blobs blob.Storage, userID int, img image.Image) (string, error) {
+	base := fmt.Sprintf("avatars/%d/%s", userID, uuid.New().String())
+	for _, size := range avatarSizes {
+		buf := &bytes.Buffer{}
+		if err := imaging.EncodePNG(buf, imaging.Square(img, size)); err != nil {
+			return "", err
+		}
+
+		if err := blobs.Put(avatarKey(base, size), buf, "image/png"); err != nil {
+			return "", err
+		}
+	}
+
+	return base, nil
+}
+
+func deleteAvatar(blobs blob.Storage, base string) error {
+	for _, size := range avatarSizes {
+		if err := blobs.Delete(avatarKey(base, size)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setAvatarURLs(u *model.User) {
+	if u.Avatar == "" {
+		return
+	}
+
+	u.AvatarURLs = make(map[string]string, len(avatarSizes))
+	for _, size := range avatarSizes {
+		u.AvatarURLs[strconv.Itoa(size)] = fmt.Sprintf("%s/user/%d/avatar/%d", apiBasePath, u.ID, size)
+	}
+}
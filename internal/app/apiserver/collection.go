@@ -0,0 +1,53 @@
+package apiserver
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// newShareSlug returns an unguessable slug for sharing a public collection.
+func newShareSlug() string {
+	return strings.Replace(uuid.New().String(), "-", "", -1)[:12]
+}
+
+// samePostIDs reports whether b is a permutation of a.
+func samePostIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	seen := make(map[int]int, len(a))
+	for _, id := range a {
+		seen[id]++
+	}
+	for _, id := range b {
+		if seen[id] == 0 {
+			return false
+		}
+		seen[id]--
+	}
+
+	return true
+}
+
+// mergeOrder fills the slots of all that hold a shown post with order, in
+// turn, and leaves the other slots as they are.
+func mergeOrder(all, shown, order []int) []int {
+	isShown := make(map[int]bool, len(shown))
+	for _, id := range shown {
+		isShown[id] = true
+	}
+
+	merged := make([]int, len(all))
+	next := 0
+	for i, id := range all {
+		if isShown[id] {
+			id = order[next]
+			next++
+		}
+		merged[i] = id
+	}
+
+	return merged
+}
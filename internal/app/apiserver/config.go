@@ -1,17 +1,87 @@
 package apiserver
 
+import (
+	"os"
+	"path/filepath"
+)
+
 // Config ...
 type Config struct {
-	BindAddr    string `toml:"bind_addr"`
-	LogLevel    string `toml:"log_level"`
-	DatabaseURL string `toml:"database_url"`
-	SessionKey  string `toml:"session_key"`
+	BindAddr             string `toml:"bind_addr"`
+	LogLevel             string `toml:"log_level"`
+	DatabaseURL          string `toml:"database_url"`
+	SessionKey           string `toml:"session_key"`
+	ExportDir            string `toml:"export_dir"`
+	ExportAsyncThreshold int    `toml:"export_async_threshold"`
+	ExportRetention      int    `toml:"export_retention"`
+	BlobDir              string `toml:"blob_dir"`
+	AvatarMaxBytes       int64  `toml:"avatar_max_bytes"`
+	PublishInterval      int    `toml:"publish_interval"`
+	UploadMaxBytes       int64  `toml:"upload_max_bytes"`
+	UploadOrphanGrace    int    `toml:"upload_orphan_grace"`
+	BlobBackend          string `toml:"blob_backend"`
+	S3Endpoint           string `toml:"s3_endpoint"`
+	S3Region             string `toml:"s3_region"`
+	S3Bucket             string `toml:"s3_bucket"`
+	S3AccessKey          string `toml:"s3_access_key"`
+	S3SecretKey          string `toml:"s3_secret_key"`
+	TrashRetention       int    `toml:"trash_retention"`
+	ReportHideThreshold  int    `toml:"report_hide_threshold"`
+	EventsBackend        string `toml:"events_backend"`
+	PublicURL            string `toml:"public_url"`
+	LegacySunset         string `toml:"legacy_sunset"`
+	IdempotencyTTL       int    `toml:"idempotency_ttl"`
+	RateLimitBackend     string `toml:"rate_limit_backend"`
+	// RateLimit applies to routes without an entry in RateLimits, which is
+	// keyed by method and route, e.g. "POST /sessions".
+	RateLimit      RateLimit            `toml:"rate_limit"`
+	RateLimits     map[string]RateLimit `toml:"rate_limits"`
+	TrustedProxies []string             `toml:"trusted_proxies"`
+	// BodyMaxBytes caps JSON request bodies on routes without an entry in
+	// BodyLimits, keyed like RateLimits.
+	BodyMaxBytes int64            `toml:"body_max_bytes"`
+	BodyLimits   map[string]int64 `toml:"body_limits"`
+	// SessionSameSite is "lax", "strict" or "none"; "none" needs SessionSecure.
+	SessionSameSite string `toml:"session_same_site"`
+	SessionSecure   bool   `toml:"session_secure"`
+	SessionHTTPOnly bool   `toml:"session_http_only"`
 }
 
 // NewConfig ...
 func NewConfig() *Config {
 	return &Config{
-		BindAddr: ":8080",
-		LogLevel: "debug",
+		BindAddr:             ":8080",
+		LogLevel:             "debug",
+		ExportDir:            filepath.Join(os.TempDir(), "booklib-exports"),
+		ExportAsyncThreshold: 500,
+		ExportRetention:      24,
+		BlobDir:              "uploads",
+		AvatarMaxBytes:       5 << 20,
+		PublishInterval:      30,
+		UploadMaxBytes:       10 << 20,
+		UploadOrphanGrace:    24,
+		BlobBackend:          "local",
+		S3Region:             "us-east-1",
+		TrashRetention:       30,
+		ReportHideThreshold:  5,
+		EventsBackend:        "memory",
+		PublicURL:            "http://localhost:8080",
+		LegacySunset:         "2027-04-30",
+		IdempotencyTTL:       24,
+		RateLimitBackend:     "memory",
+		RateLimit:            RateLimit{Rate: 10, Burst: 50},
+		RateLimits: map[string]RateLimit{
+			"POST /sessions":      {Rate: 1.0 / 12, Burst: 5},
+			"POST /users":         {Rate: 1.0 / 60, Burst: 3},
+			"POST /private/posts": {Rate: 1.0 / 6, Burst: 10},
+		},
+		BodyMaxBytes: 64 << 10,
+		BodyLimits: map[string]int64{
+			"POST /private/posts":       1 << 20,
+			"PUT /private/posts/{id}":   1 << 20,
+			"PATCH /private/posts/{id}": 1 << 20,
+		},
+		SessionSameSite: "lax",
+		SessionHTTPOnly: true,
 	}
 }
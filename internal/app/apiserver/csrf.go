@@ -0,0 +1,103 @@
+package apiserver
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+const (
+	csrfHeader     = "X-CSRF-Token"
+	csrfSessionKey = "csrf_token"
+)
+
+// csrfTokenResponse carries the token unsafe requests must send in X-CSRF-Token.
+type csrfTokenResponse struct {
+	CSRFToken string `json:"csrf_token"`
+}
+
+// protectCSRF rejects unsafe requests from a cookie session unless they echo
+// the session's synchronizer token in X-CSRF-Token. Other sites can make a
+// browser send our cookie but can't read the token or set the header.
+// Requests with a bearer token don't use the cookie and aren't checked.
+func (s *server) protectCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET", "HEAD", "OPTIONS":
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if hasBearerToken(r) || r.Context().Value(ctxKeyUser) == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, err := s.sessionStore.Get(r, sessionName)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		expected, _ := session.Values[csrfSessionKey].(string)
+		got := r.Header.Get(csrfHeader)
+		if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(got)) != 1 {
+			s.error(w, r, http.StatusForbidden, errInvalidCSRFToken)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func hasBearerToken(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	return len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ")
+}
+
+// csrfToken returns the session's token, adding one if it has none yet.
+// The caller saves the session.
+func csrfToken(session *sessions.Session) (string, error) {
+	if token, ok := session.Values[csrfSessionKey].(string); ok && token != "" {
+		return token, nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	session.Values[csrfSessionKey] = token
+	return token, nil
+}
+
+// handleCSRFToken hands out the token for sessions that predate it or whose
+// client lost it.
+func (s *server) handleCSRFToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.sessionStore.Get(r, sessionName)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		token, err := csrfToken(session)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err := s.sessionStore.Save(r, w, session); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		w.Header().Set(csrfHeader, token)
+		s.respond(w, r, http.StatusOK, &csrfTokenResponse{CSRFToken: token})
+	}
+}
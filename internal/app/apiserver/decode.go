@@ -0,0 +1,142 @@
+package apiserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// decodeJSON reads a JSON request body into v. It requires an
+// application/json body within the route's size limit holding a single value
+// with no fields v doesn't have; otherwise it responds with a problem
+// describing what's wrong and returns false.
+func (s *server) decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	body, ok := s.readBody(w, r, "application/json")
+	if !ok {
+		return false
+	}
+
+	if err := decodeStrict(body, v); err != nil {
+		s.error(w, r, http.StatusBadRequest, err)
+		return false
+	}
+
+	return true
+}
+
+// readBody reads the request body after checking it's one of the given media
+// types and no larger than the route allows.
+func (s *server) readBody(w http.ResponseWriter, r *http.Request, mediaTypes ...string) ([]byte, bool) {
+	if !hasMediaType(r, mediaTypes...) {
+		s.error(w, r, http.StatusUnsupportedMediaType, fmt.Errorf("%w: expected %s", errUnsupportedMediaType, strings.Join(mediaTypes, " or ")))
+		return nil, false
+	}
+
+	limit := s.bodyLimit(r)
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		s.error(w, r, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	if int64(len(body)) > limit {
+		s.error(w, r, http.StatusRequestEntityTooLarge, fmt.Errorf("%w: the limit is %d bytes", errBodyTooLarge, limit))
+		return nil, false
+	}
+
+	return body, true
+}
+
+// bodyLimit is the largest body the matched route accepts.
+func (s *server) bodyLimit(r *http.Request) int64 {
+	if limit, ok := s.config.BodyLimits[routeKey(r)]; ok {
+		return limit
+	}
+
+	return s.config.BodyMaxBytes
+}
+
+// decodeStrict decodes data into v, describing bad input in terms of the
+// request: malformed JSON and trailing data are reported with their offset,
+// and wrongly typed or unknown fields as validation errors on those fields.
+func decodeStrict(data []byte, v interface{}) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return errBodyEmpty
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return describeJSONError(err)
+	}
+
+	end := dec.InputOffset()
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("%w at offset %d", errTrailingData, end)
+	}
+
+	return nil
+}
+
+func describeJSONError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("%w at offset %d: %s", errMalformedJSON, syntaxErr.Offset, syntaxErr.Error())
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("%w: unexpected end of input", errMalformedJSON)
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Errorf("%w: expected %s, got %s", errMalformedJSON, jsonTypeName(typeErr.Type), typeErr.Value)
+		}
+
+		return validation.Errors{
+			typeErr.Field: fmt.Errorf("must be %s", jsonTypeName(typeErr.Type)),
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		name, unquoteErr := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		if unquoteErr != nil {
+			return err
+		}
+
+		return validation.Errors{name: errUnknownField}
+	}
+
+	return err
+}
+
+// jsonTypeName names the JSON type a Go type is decoded from.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Ptr:
+		return jsonTypeName(t.Elem())
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return "an RFC 3339 timestamp"
+	}
+
+	return "an object"
+}
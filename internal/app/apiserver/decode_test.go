@@ -0,0 +1,88 @@
+package apiserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+type decodeTarget struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestDecodeStrict(t *testing.T) {
+	testCases := []struct {
+		name      string
+		body      string
+		wantErr   error
+		wantField string
+	}{
+		{name: "valid", body: `{"name":"a","count":1}`},
+		{name: "surrounding whitespace", body: " {\"name\":\"a\"}\n"},
+		{name: "empty", body: "", wantErr: errBodyEmpty},
+		{name: "blank", body: " \n", wantErr: errBodyEmpty},
+		{name: "unknown field", body: `{"name":"a","admin":true}`, wantField: "admin"},
+		{name: "wrong type", body: `{"count":"many"}`, wantField: "count"},
+		{name: "trailing object", body: `{"name":"a"}{"name":"b"}`, wantErr: errTrailingData},
+		{name: "trailing garbage", body: `{"name":"a"} x`, wantErr: errTrailingData},
+		{name: "malformed", body: `{"name":}`, wantErr: errMalformedJSON},
+		{name: "truncated", body: `{"name":"a"`, wantErr: errMalformedJSON},
+		{name: "not an object", body: `[1]`, wantErr: errMalformedJSON},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := decodeStrict([]byte(tc.body), &decodeTarget{})
+			switch {
+			case tc.wantErr != nil:
+				assert.ErrorIs(t, err, tc.wantErr)
+			case tc.wantField != "":
+				if assert.IsType(t, validation.Errors{}, err) {
+					assert.Contains(t, err.(validation.Errors), tc.wantField)
+				}
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestServer_DecodeJSON(t *testing.T) {
+	config := NewConfig()
+	config.BodyMaxBytes = 32
+	s := newServer(nil, sessions.NewCookieStore([]byte("secret")), nil, config)
+
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{name: "valid", contentType: "application/json", body: `{"name":"a"}`, wantStatus: http.StatusOK},
+		{name: "with charset", contentType: "application/json; charset=utf-8", body: `{"name":"a"}`, wantStatus: http.StatusOK},
+		{name: "at the limit", contentType: "application/json", body: `{"name":"` + strings.Repeat("a", 21) + `"}`, wantStatus: http.StatusOK},
+		{name: "over the limit", contentType: "application/json", body: `{"name":"` + strings.Repeat("a", 22) + `"}`, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "wrong media type", contentType: "text/plain", body: `{"name":"a"}`, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "unknown field", contentType: "application/json", body: `{"nmae":"a"}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "trailing data", contentType: "application/json", body: `{"name":"a"}{}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+
+			if s.decodeJSON(rec, req, &decodeTarget{}) {
+				rec.WriteHeader(http.StatusOK)
+			}
+			assert.Equal(t, tc.wantStatus, rec.Code)
+		})
+	}
+}
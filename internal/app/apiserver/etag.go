@@ -0,0 +1,106 @@
+package apiserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+// postETag identifies a version of a post. Every write to the post, including
+// changes to its attachment set, bumps its version, so the tag changes
+// whenever the stored post does. Star counts aren't part of the version;
+// responses that include them are tagged with contentETag instead.
+func postETag(p *model.Post) string {
+	return fmt.Sprintf(`"%d-%d"`, p.ID, p.Version)
+}
+
+// contentETag derives a strong tag from a response body.
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%x"`, sum[:16])
+}
+
+// etagListed reports whether etag is among the comma-separated tags of an
+// If-Match or If-None-Match header. Strong comparison rejects weak tags.
+func etagListed(header string, etag string, strong bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(tag, "W/") {
+			if strong {
+				continue
+			}
+			tag = tag[2:]
+		}
+
+		if tag == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkIfMatch fails the request with 412 when it carries an If-Match header
+// that doesn't name the post's current version. Requests without the header
+// go through, so clients that don't track versions keep working.
+func (s *server) checkIfMatch(w http.ResponseWriter, r *http.Request, p *model.Post) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || etagListed(header, postETag(p), true) {
+		return true
+	}
+
+	w.Header().Set("ETag", postETag(p))
+	s.error(w, r, http.StatusPreconditionFailed, errPreconditionFailed)
+	return false
+}
+
+// ifMatchVersion is the version a write must still find for the request's
+// If-Match to hold, or 0 when the request has none. Passing it to the store
+// repeats checkIfMatch in the write itself, so a concurrent edit can't slip in
+// between the two.
+func ifMatchVersion(r *http.Request, p *model.Post) int {
+	if r.Header.Get("If-Match") == "" {
+		return 0
+	}
+
+	return p.Version
+}
+
+// notModified sets the response's ETag and answers 304 when the request's
+// If-None-Match already names it.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if !etagListed(r.Header.Get("If-None-Match"), etag, false) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// respondCached responds like respond, tagging the body with a content hash
+// so clients can revalidate with If-None-Match.
+func (s *server) respondCached(w http.ResponseWriter, r *http.Request, data interface{}) {
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(data); err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if notModified(w, r, contentETag(buf.Bytes())) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
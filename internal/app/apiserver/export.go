@@ -0,0 +1,171 @@
+package apiserver
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+const (
+	exportStatusPending = "pending"
+	exportStatusDone    = "done"
+	exportStatusFailed  = "failed"
+)
+
+var (
+	errExportNotFound = errors.New("export not found")
+	errExportNotReady = errors.New("export is not ready yet")
+)
+
+// userExport is everything we know about a user, as written to the archive.
+type userExport struct {
+	Profile  *model.User              `json:"profile"`
+	Posts    []model.Post             `json:"posts"`
+	Stars    []model.Star             `json:"stars"`
+	Sessions []map[string]interface{} `json:"sessions"`
+}
+
+type exportJob struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	userID    int
+	path      string
+}
+
+type exporter struct {
+	mu   sync.Mutex
+	dir  string
+	jobs map[string]*exportJob
+}
+
+func newExporter(dir string) *exporter {
+	return &exporter{
+		dir:  dir,
+		jobs: make(map[string]*exportJob),
+	}
+}
+
+// start collects the user's data and writes the archive in the background,
+// returning the job tracking it.
+func (e *exporter) start(userID int, collect func() (*userExport, error)) *exportJob {
+	job := &exportJob{
+		ID:        uuid.New().String(),
+		Status:    exportStatusPending,
+		CreatedAt: time.Now(),
+		userID:    userID,
+		path:      filepath.Join(e.dir, uuid.New().String()+".zip"),
+	}
+
+	e.mu.Lock()
+	e.jobs[job.ID] = job
+	e.mu.Unlock()
+
+	go func() {
+		data, err := collect()
+		if err == nil {
+			err = e.writeFile(job.path, data)
+		}
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if err != nil {
+			job.Status = exportStatusFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = exportStatusDone
+	}()
+
+	return job
+}
+
+// find returns a copy of the job so callers can read it without holding the lock.
+func (e *exporter) find(userID int, id string) (exportJob, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	job, ok := e.jobs[id]
+	if !ok || job.userID != userID {
+		return exportJob{}, errExportNotFound
+	}
+
+	return *job, nil
+}
+
+// sweep forgets finished jobs created before the given time and deletes their archives.
+func (e *exporter) sweep(before time.Time) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	n := 0
+	for id, job := range e.jobs {
+		if job.Status == exportStatusPending || !job.CreatedAt.Before(before) {
+			continue
+		}
+
+		if err := os.Remove(job.path); err != nil && !os.IsNotExist(err) {
+			return n, err
+		}
+		delete(e.jobs, id)
+		n++
+	}
+
+	return n, nil
+}
+
+func (e *exporter) writeFile(path string, data *userExport) error {
+	if err := os.MkdirAll(e.dir, 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := writeExport(f, data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+
+	return f.Close()
+}
+
+func writeExport(w io.Writer, data *userExport) error {
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"profile.json", data.Profile},
+		{"posts.json", data.Posts},
+		{"stars.json", data.Stars},
+		{"sessions.json", data.Sessions},
+	}
+
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.v); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
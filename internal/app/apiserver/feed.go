@@ -0,0 +1,120 @@
+package apiserver
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/zlyaptica/http-rest-api/internal/app/feed"
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+// Feed formats ...
+const (
+	feedRSS  = "rss"
+	feedAtom = "atom"
+)
+
+const feedSize = 20
+
+func (s *server) handleSiteFeed(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		posts, err := s.store.Post().FindAll(feedSize, 0)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		f := s.newFeed("Booklib", "Latest posts", apiBasePath+"/feed."+format, posts)
+		s.writeFeed(w, r, format, f)
+	}
+}
+
+func (s *server) handleUserFeed(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		u, err := s.store.User().FindByID(id)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		posts, err := s.store.Post().FindByAuthor(u.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if len(posts) > feedSize {
+			posts = posts[:feedSize]
+		}
+
+		title := u.Username
+		if u.DisplayName != "" {
+			title = u.DisplayName
+		}
+
+		f := s.newFeed(title, "Posts by "+u.Username, fmt.Sprintf("%s/user/%d/feed.%s", apiBasePath, u.ID, format), posts)
+		s.writeFeed(w, r, format, f)
+	}
+}
+
+func (s *server) newFeed(title string, description string, path string, posts []model.Post) *feed.Feed {
+	base := strings.TrimSuffix(s.config.PublicURL, "/")
+	f := &feed.Feed{
+		Title:       title,
+		Link:        base + "/",
+		Self:        base + path,
+		Description: description,
+	}
+
+	for _, p := range posts {
+		updated := p.CreatedAt
+		if p.EditedAt != nil && p.EditedAt.After(updated) {
+			updated = *p.EditedAt
+		}
+		if updated.After(f.Updated) {
+			f.Updated = updated
+		}
+
+		f.Items = append(f.Items, feed.Item{
+			ID:        base + apiBasePath + "/posts/" + strconv.Itoa(p.ID),
+			Title:     p.Header,
+			Link:      base + apiBasePath + "/posts/" + p.Slug,
+			Author:    p.Author.Username,
+			Published: p.CreatedAt,
+			Updated:   updated,
+			Content:   p.TextHTML,
+		})
+	}
+
+	return f
+}
+
+// writeFeed renders the feed and serves it with ETag and Last-Modified so
+// that readers polling an unchanged feed get 304 Not Modified.
+func (s *server) writeFeed(w http.ResponseWriter, r *http.Request, format string, f *feed.Feed) {
+	buf := &bytes.Buffer{}
+	render, contentType := feed.Atom, "application/atom+xml; charset=utf-8"
+	if format == feedRSS {
+		render, contentType = feed.RSS, "application/rss+xml; charset=utf-8"
+	}
+	if err := render(buf, f); err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", contentETag(buf.Bytes()))
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// ServeContent answers If-None-Match and If-Modified-Since for us.
+	http.ServeContent(w, r, "", f.Updated, bytes.NewReader(buf.Bytes()))
+}
@@ -0,0 +1,259 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+// Event types ...
+const (
+	eventPostCreated  = "post.created"
+	eventPostStars    = "post.stars"
+	eventNotification = "notification"
+	eventPostTyping   = "post.typing"
+)
+
+const (
+	hubHistorySize   = 256
+	subscriberBuffer = 64
+)
+
+// event is a single real-time update. Events with a UserID are delivered
+// only to that user's streams, the rest are broadcast to everyone. Events
+// with a PostID reach channel subscribers only if they joined that post.
+type event struct {
+	ID     uint64          `json:"-"`
+	Type   string          `json:"type"`
+	UserID int             `json:"user_id,omitempty"`
+	PostID int             `json:"post_id,omitempty"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type postEvent struct {
+	ID       int    `json:"id"`
+	Slug     string `json:"slug"`
+	Header   string `json:"header"`
+	AuthorID int    `json:"author_id"`
+}
+
+type starsEvent struct {
+	PostID     int `json:"post_id"`
+	StarsCount int `json:"stars_count"`
+}
+
+type typingEvent struct {
+	PostID int         `json:"post_id"`
+	User   *model.User `json:"user"`
+}
+
+// subscriber receives events for one connection. A nil posts set means
+// every post; otherwise only events for the joined posts get through.
+type subscriber struct {
+	userID int
+	posts  map[int]bool
+	events chan *event
+}
+
+func (sub *subscriber) wants(e *event) bool {
+	if e.UserID != 0 && e.UserID != sub.userID {
+		return false
+	}
+
+	return sub.posts == nil || e.PostID == 0 || sub.posts[e.PostID]
+}
+
+// hub fans events out to the streams connected to this instance. It keeps a
+// short history so that reconnecting clients can resume from Last-Event-ID.
+// Event IDs are local to the instance: with a relay every instance numbers
+// the events it receives on its own.
+type hub struct {
+	mu      sync.Mutex
+	logger  *logrus.Logger
+	lastID  uint64
+	history []*event
+	subs    map[*subscriber]struct{}
+	relay   func(*event) error
+}
+
+func newHub(logger *logrus.Logger) *hub {
+	return &hub{
+		logger: logger,
+		subs:   make(map[*subscriber]struct{}),
+	}
+}
+
+// publish sends an event through the relay when one is configured, or straight to local subscribers otherwise.
+func (h *hub) publish(eventType string, userID int, postID int, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		h.logger.Errorf("encoding %s event: %v", eventType, err)
+		return
+	}
+
+	e := &event{
+		Type:   eventType,
+		UserID: userID,
+		PostID: postID,
+		Data:   raw,
+	}
+	if h.relay == nil {
+		h.deliver(e)
+		return
+	}
+
+	if err := h.relay(e); err != nil {
+		h.logger.Errorf("relaying %s event: %v", eventType, err)
+	}
+}
+
+// publishLocal hands a transient event, such as typing presence, to this
+// instance's subscribers of the post. It isn't relayed, numbered or kept in
+// the history, so reconnecting clients never get it replayed, and it's
+// dropped rather than disconnecting subscribers that can't keep up.
+func (h *hub) publishLocal(eventType string, postID int, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		h.logger.Errorf("encoding %s event: %v", eventType, err)
+		return
+	}
+
+	e := &event{
+		Type:   eventType,
+		PostID: postID,
+		Data:   raw,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		if !sub.posts[postID] {
+			continue
+		}
+
+		select {
+		case sub.events <- e:
+		default:
+		}
+	}
+}
+
+// deliver numbers the event and hands it to local subscribers. Subscribers
+// that can't keep up are disconnected; they resume from Last-Event-ID.
+func (h *hub) deliver(e *event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	e.ID = h.lastID
+	h.history = append(h.history, e)
+	if len(h.history) > hubHistorySize {
+		h.history = append(h.history[:0], h.history[1:]...)
+	}
+
+	for sub := range h.subs {
+		if !sub.wants(e) {
+			continue
+		}
+
+		select {
+		case sub.events <- e:
+		default:
+			delete(h.subs, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// subscribe registers a stream for userID (0 for anonymous viewers) and
+// returns the events it missed since lastID.
+func (h *hub) subscribe(userID int, lastID uint64) (*subscriber, []*event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &subscriber{
+		userID: userID,
+		events: make(chan *event, subscriberBuffer),
+	}
+	h.subs[sub] = struct{}{}
+
+	missed := []*event{}
+	if lastID == 0 || lastID > h.lastID {
+		return sub, missed
+	}
+
+	for _, e := range h.history {
+		if e.ID > lastID && sub.wants(e) {
+			missed = append(missed, e)
+		}
+	}
+
+	return sub, missed
+}
+
+// subscribeChannels registers a connection that only receives post events for the posts it joins.
+func (h *hub) subscribeChannels(userID int) *subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &subscriber{
+		userID: userID,
+		posts:  make(map[int]bool),
+		events: make(chan *event, subscriberBuffer),
+	}
+	h.subs[sub] = struct{}{}
+
+	return sub
+}
+
+func (h *hub) join(sub *subscriber, postID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub.posts[postID] = true
+}
+
+func (h *hub) leave(sub *subscriber, postID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(sub.posts, postID)
+}
+
+func (h *hub) joined(sub *subscriber, postID int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return sub.posts[postID]
+}
+
+func (h *hub) unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
+
+// announcePost broadcasts a newly published post.
+func (s *server) announcePost(p *model.Post) {
+	s.hub.publish(eventPostCreated, 0, 0, &postEvent{
+		ID:       p.ID,
+		Slug:     p.Slug,
+		Header:   p.Header,
+		AuthorID: p.Author.ID,
+	})
+}
+
+// announceStars broadcasts a post's new star count.
+func (s *server) announceStars(postID int, count int) {
+	s.hub.publish(eventPostStars, 0, postID, &starsEvent{
+		PostID:     postID,
+		StarsCount: count,
+	})
+}
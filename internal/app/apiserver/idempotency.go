@@ -0,0 +1,219 @@
+package apiserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	idempotencyKeyMaxLen = 255
+)
+
+// idempotencyReplayedHeaders are the response headers stored with a key and
+// sent again on replay.
+var idempotencyReplayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// idempotencyCutoff is the creation time before which stored keys are forgotten.
+func (s *server) idempotencyCutoff() time.Time {
+	return time.Now().Add(-time.Duration(s.config.IdempotencyTTL) * time.Hour)
+}
+
+// idempotent makes a create endpoint safe to retry. The first request with a
+// given Idempotency-Key runs normally and its response is stored for the
+// user; later requests with the same key get that response back instead of
+// creating again. Reusing a key for a different request is rejected with 422
+// and retrying while the first request is still running with 409, as in
+// draft-ietf-httpapi-idempotency-key-header.
+func (s *server) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > idempotencyKeyMaxLen {
+			s.error(w, r, http.StatusBadRequest, errInvalidIdempotencyKey)
+			return
+		}
+
+		// Uploads are multipart and limited separately from JSON bodies.
+		limit := s.bodyLimit(r)
+		if hasMediaType(r, "multipart/form-data") {
+			limit = s.config.UploadMaxBytes
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.error(w, r, http.StatusRequestEntityTooLarge, fmt.Errorf("%w: the limit is %d bytes", errBodyTooLarge, limit))
+			return
+		}
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		user := r.Context().Value(ctxKeyUser).(*model.User)
+		k := &model.IdempotencyKey{
+			UserID:      user.ID,
+			Key:         key,
+			RequestHash: requestHash(r, body),
+		}
+
+		reserved, err := s.store.Idempotency().Reserve(k, s.idempotencyCutoff())
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if !reserved {
+			s.replay(w, r, k)
+			return
+		}
+
+		// Unless a final response gets stored, release the key so retries
+		// aren't stuck behind a reservation nothing will complete: failures
+		// on our side, a failed Complete and a panicking handler all end here.
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+
+			if err := s.store.Idempotency().Delete(k.UserID, k.Key); err != nil {
+				s.logger.Errorf("releasing idempotency key: %v", err)
+			}
+		}()
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		if rec.status >= http.StatusInternalServerError {
+			return
+		}
+
+		k.Status = rec.status
+		k.Body = rec.body.Bytes()
+		k.Headers = map[string]string{}
+		for _, name := range idempotencyReplayedHeaders {
+			if v := w.Header().Get(name); v != "" {
+				k.Headers[name] = v
+			}
+		}
+		if err := s.store.Idempotency().Complete(k); err != nil {
+			s.logger.Errorf("storing idempotent response: %v", err)
+			return
+		}
+		completed = true
+	})
+}
+
+// replay answers a retried request with the stored response for its key.
+func (s *server) replay(w http.ResponseWriter, r *http.Request, k *model.IdempotencyKey) {
+	stored, err := s.store.Idempotency().Find(k.UserID, k.Key)
+	if err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if stored.RequestHash != k.RequestHash {
+		s.error(w, r, http.StatusUnprocessableEntity, errIdempotencyKeyReused)
+		return
+	}
+
+	if stored.Status == 0 {
+		s.error(w, r, http.StatusConflict, errIdempotencyKeyInFlight)
+		return
+	}
+
+	for name, v := range stored.Headers {
+		w.Header().Set(name, v)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(stored.Status)
+	w.Write(stored.Body)
+}
+
+// requestHash fingerprints a request so a reused key can be told apart from
+// a retry. The version prefix is dropped: /v1/x and its legacy alias are the
+// same request. Multipart bodies are hashed by their fields and files, since
+// clients pick a new boundary every time they encode the same form.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, strings.TrimPrefix(r.URL.Path, apiBasePath))
+	if !hashMultipart(h, r, body) {
+		h.Write(body)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// hashMultipart writes each part's field name, file name and content to h.
+// It reports false, leaving h untouched, when the body isn't a valid
+// multipart form.
+func hashMultipart(h io.Writer, r *http.Request, body []byte) bool {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return false
+	}
+
+	parts := sha256.New()
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false
+		}
+
+		content := sha256.New()
+		if _, err := io.Copy(content, part); err != nil {
+			return false
+		}
+		fmt.Fprintf(parts, "%q %q %x\n", part.FormName(), part.FileName(), content.Sum(nil))
+	}
+
+	h.Write(parts.Sum(nil))
+	return true
+}
+
+// idempotencyRecorder passes the response through while keeping a copy.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *idempotencyRecorder) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *idempotencyRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,167 @@
+package apiserver
+
+import (
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+	"github.com/zlyaptica/http-rest-api/internal/app/store"
+)
+
+const (
+	notifierQueueSize = 1024
+	maxMentions       = 20
+)
+
+var mentionRe = regexp.MustCompile(`(?:^|[^\w@])@(\w+)`)
+
+// notifier creates notifications off the request path: handlers enqueue
+// work and a single worker resolves mentions, checks preferences and writes
+// the notifications.
+type notifier struct {
+	store  store.Store
+	logger *logrus.Logger
+	hub    *hub
+	queue  chan func()
+}
+
+func newNotifier(store store.Store, logger *logrus.Logger, h *hub) *notifier {
+	return &notifier{
+		store:  store,
+		logger: logger,
+		hub:    h,
+		queue:  make(chan func(), notifierQueueSize),
+	}
+}
+
+// notify enqueues a notification without blocking. Notifying users about their own actions is skipped.
+func (n *notifier) notify(userID int, actor *model.User, notificationType string, postID *int) {
+	notification := n.notification(userID, actor, notificationType, postID)
+	if notification == nil {
+		return
+	}
+
+	n.enqueue(func() { n.create(notification) }, "dropping %s notification for user %d", notificationType, userID)
+}
+
+// notifyOnce is notify for actions that can be undone and repeated, like
+// following: it's skipped while the user hasn't read the last one yet.
+func (n *notifier) notifyOnce(userID int, actor *model.User, notificationType string, postID *int) {
+	notification := n.notification(userID, actor, notificationType, postID)
+	if notification == nil {
+		return
+	}
+
+	n.enqueue(func() {
+		unread, err := n.store.Notification().HasUnread(notification)
+		if err != nil {
+			n.logger.Errorf("checking unread %s notifications of user %d: %v", notificationType, userID, err)
+			return
+		}
+
+		if !unread {
+			n.create(notification)
+		}
+	}, "dropping %s notification for user %d", notificationType, userID)
+}
+
+// notification builds the notification, or returns nil when the actor is the user.
+func (n *notifier) notification(userID int, actor *model.User, notificationType string, postID *int) *model.Notification {
+	notification := &model.Notification{
+		UserID: userID,
+		Type:   notificationType,
+		PostID: postID,
+	}
+	if actor != nil {
+		if actor.ID == userID {
+			return nil
+		}
+		notification.Actor = &model.User{ID: actor.ID, Username: actor.Username}
+	}
+
+	return notification
+}
+
+// enqueue hands job to the worker without blocking, logging what's dropped when the queue is full.
+func (n *notifier) enqueue(job func(), dropped string, args ...interface{}) {
+	select {
+	case n.queue <- job:
+	default:
+		n.logger.Warnf("notification queue full, "+dropped, args...)
+	}
+}
+
+func (n *notifier) run() {
+	for job := range n.queue {
+		job()
+	}
+}
+
+func (n *notifier) create(notification *model.Notification) {
+	enabled, err := n.store.Notification().IsEnabled(notification.UserID, notification.Type)
+	if err != nil {
+		n.logger.Errorf("checking notification preferences of user %d: %v", notification.UserID, err)
+		return
+	}
+
+	if !enabled {
+		return
+	}
+
+	if err := n.store.Notification().Create(notification); err != nil {
+		n.logger.Errorf("creating %s notification for user %d: %v", notification.Type, notification.UserID, err)
+		return
+	}
+
+	n.hub.publish(eventNotification, notification.UserID, 0, notification)
+}
+
+// notifyMentions enqueues notifications for users @-mentioned in text who weren't already mentioned in previous.
+func (n *notifier) notifyMentions(author *model.User, post *model.Post, text string, previous string) {
+	postID := post.ID
+	n.enqueue(func() { n.resolveMentions(author, postID, text, previous) }, "dropping mentions in post %d", postID)
+}
+
+func (n *notifier) resolveMentions(author *model.User, postID int, text string, previous string) {
+	already := make(map[string]bool)
+	for _, name := range mentions(previous) {
+		already[name] = true
+	}
+
+	names := []string{}
+	for _, name := range mentions(text) {
+		if !already[name] {
+			names = append(names, name)
+		}
+	}
+
+	users, err := n.store.User().FindByUsernames(names)
+	if err != nil {
+		n.logger.Errorf("resolving mentions in post %d: %v", postID, err)
+		return
+	}
+
+	for _, u := range users {
+		n.notify(u.ID, author, model.NotificationMention, &postID)
+	}
+}
+
+// mentions returns the distinct usernames @-mentioned in text.
+func mentions(text string) []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, m := range mentionRe.FindAllStringSubmatch(text, -1) {
+		name := m[1]
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		names = append(names, name)
+		if len(names) == maxMentions {
+			break
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,633 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+	"github.com/zlyaptica/http-rest-api/internal/app/diff"
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+// apiOperation describes one route of configureAPI for the OpenAPI document.
+type apiOperation struct {
+	method     string
+	path       string
+	summary    string
+	paged      bool
+	cached     bool // answers If-None-Match with 304
+	ifMatch    bool // fails with 412 when If-Match names another version
+	idempotent bool // replays the first response to retries with the same Idempotency-Key
+	query      []string
+	params     map[string]string
+	request    interface{}
+	mediaType  string
+	form       string
+	responses  []apiResponse
+}
+
+type apiResponse struct {
+	status      int
+	body        interface{}
+	contentType string
+}
+
+// listOf stands for the {"items": [...]} envelope most list endpoints respond with.
+type listOf struct {
+	item interface{}
+}
+
+func okResponse(body interface{}) []apiResponse {
+	return []apiResponse{{status: http.StatusOK, body: body}}
+}
+
+func createdResponse(body interface{}) []apiResponse {
+	return []apiResponse{{status: http.StatusCreated, body: body}}
+}
+
+func noContentResponse() []apiResponse {
+	return []apiResponse{{status: http.StatusNoContent}}
+}
+
+func fileResponse(contentType string) []apiResponse {
+	return []apiResponse{{status: http.StatusOK, contentType: contentType}}
+}
+
+// The request and response bodies of handlers that declare their own types,
+// field for field.
+var (
+	signUpBody = struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}{}
+	signInBody = struct {
+		Username   string `json:"username"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		RememberMe bool   `json:"rememberMe"`
+	}{}
+	postCreateBody = struct {
+		Header        string     `json:"header"`
+		TextPost      string     `json:"text_post"`
+		Status        string     `json:"status"`
+		PublishAt     *time.Time `json:"publish_at"`
+		AttachmentIDs []int      `json:"attachment_ids"`
+	}{}
+	postUpdateBody = struct {
+		Header        *string `json:"header"`
+		TextPost      *string `json:"text_post"`
+		AttachmentIDs []int   `json:"attachment_ids"`
+	}{}
+	postStatusBody = struct {
+		Status    string     `json:"status"`
+		PublishAt *time.Time `json:"publish_at"`
+	}{}
+	postItemBody = struct {
+		Item *model.Post `json:"item"`
+	}{}
+	// legacyPostItemBody is how v1 wraps an own post.
+	legacyPostItemBody = struct {
+		Item *model.Post `json:"items"`
+	}{}
+	revisionsDiffBody = struct {
+		From   string      `json:"from"`
+		To     string      `json:"to"`
+		Header []diff.Line `json:"header"`
+		Text   []diff.Line `json:"text_post"`
+	}{}
+	reportCreateBody = struct {
+		Reason  string `json:"reason"`
+		Comment string `json:"comment"`
+	}{}
+	reportResolveBody = struct {
+		Resolution string `json:"resolution"`
+	}{}
+	notificationsBody = struct {
+		Items       []model.Notification `json:"items"`
+		UnreadCount int                  `json:"unread_count"`
+	}{}
+	notificationsReadBody = struct {
+		IDs []int `json:"ids"`
+	}{}
+	notificationsMarkedBody = struct {
+		Marked int `json:"marked"`
+	}{}
+	userBody = struct {
+		User *model.User `json:"user"`
+	}{}
+	profileBody = struct {
+		DisplayName string `json:"display_name"`
+		Bio         string `json:"bio"`
+		Website     string `json:"website"`
+		Location    string `json:"location"`
+	}{}
+	roleBody = struct {
+		Role string `json:"role"`
+	}{}
+	collectionBody = struct {
+		Name     string `json:"name"`
+		IsPublic bool   `json:"is_public"`
+	}{}
+	collectionPostBody = struct {
+		PostID int `json:"post_id"`
+	}{}
+	collectionOrderBody = struct {
+		PostIDs []int `json:"post_ids"`
+	}{}
+	exportStatusBody = struct {
+		exportJob
+		URL string `json:"url,omitempty"`
+	}{}
+)
+
+var apiOperations = []apiOperation{
+	{method: "POST", path: "/users", summary: "Register a user", request: signUpBody, responses: createdResponse(model.User{})},
+	{method: "POST", path: "/sessions", summary: "Sign in and receive a session cookie and its CSRF token", request: signInBody, responses: okResponse(csrfTokenResponse{})},
+	{method: "GET", path: "/posts", summary: "List published posts", cached: true, paged: true, responses: okResponse(listOf{model.Post{}})},
+	{method: "GET", path: "/posts/{ref}", summary: "Get a post by ID or slug", cached: true, params: map[string]string{"ref": "string"}, responses: []apiResponse{
+		{status: http.StatusOK, body: postItemBody},
+		{status: http.StatusMovedPermanently},
+	}},
+	{method: "GET", path: "/posts/{id}/revisions", summary: "List a post's revisions", responses: okResponse(listOf{model.Revision{}})},
+	{method: "GET", path: "/posts/{id}/revisions/diff", summary: "Diff two revisions of a post", query: []string{"from", "to"}, responses: okResponse(revisionsDiffBody)},
+	{method: "GET", path: "/user/{id}", summary: "Get a user's public profile", responses: okResponse(userBody)},
+	{method: "GET", path: "/user/{id}/posts", summary: "List a user's published posts", cached: true, responses: okResponse(listOf{model.Post{}})},
+	{method: "GET", path: "/user/{id}/avatar/{size}", summary: "Get a user's avatar", params: map[string]string{"size": "string"}, responses: fileResponse("image/png")},
+	{method: "GET", path: "/user/{id}/followers", summary: "List a user's followers", responses: okResponse(listOf{model.User{}})},
+	{method: "GET", path: "/user/{id}/following", summary: "List the users a user follows", responses: okResponse(listOf{model.User{}})},
+	{method: "GET", path: "/user/{id}/feed.rss", summary: "RSS feed of a user's posts", responses: fileResponse("application/rss+xml")},
+	{method: "GET", path: "/user/{id}/feed.atom", summary: "Atom feed of a user's posts", responses: fileResponse("application/atom+xml")},
+	{method: "GET", path: "/uploads/{id}", summary: "Download an attachment", responses: fileResponse("*/*")},
+	{method: "GET", path: "/uploads/{id}/thumb", summary: "Download an image attachment's thumbnail", responses: fileResponse("image/*")},
+	{method: "GET", path: "/collections/{slug}", summary: "Get a shared collection", params: map[string]string{"slug": "string"}, responses: okResponse(model.Collection{})},
+	{method: "GET", path: "/feed.rss", summary: "RSS feed of the latest posts", responses: fileResponse("application/rss+xml")},
+	{method: "GET", path: "/feed.atom", summary: "Atom feed of the latest posts", responses: fileResponse("application/atom+xml")},
+	{method: "GET", path: "/stream", summary: "Server-Sent Events with new posts, star counts and the viewer's notifications", responses: fileResponse("text/event-stream")},
+	{method: "GET", path: "/ws", summary: "WebSocket for post channels, live stars and typing presence", responses: []apiResponse{{status: http.StatusSwitchingProtocols}}},
+
+	{method: "GET", path: "/private/whoami", summary: "Get the signed-in user", responses: okResponse(model.User{})},
+	{method: "GET", path: "/private/csrf-token", summary: "Get the session's CSRF token", responses: okResponse(csrfTokenResponse{})},
+	{method: "GET", path: "/private/feed", summary: "List posts by followed users", cached: true, paged: true, responses: okResponse(listOf{model.Post{}})},
+	{method: "POST", path: "/private/user/{id}/follow", summary: "Follow a user", responses: createdResponse(model.Follow{})},
+	{method: "DELETE", path: "/private/user/{id}/follow", summary: "Unfollow a user", responses: noContentResponse()},
+	{method: "POST", path: "/private/collections", summary: "Create a collection", idempotent: true, request: collectionBody, responses: createdResponse(model.Collection{})},
+	{method: "GET", path: "/private/collections", summary: "List own collections", responses: okResponse(listOf{model.Collection{}})},
+	{method: "GET", path: "/private/collections/{id}", summary: "Get an own collection", responses: okResponse(model.Collection{})},
+	{method: "PUT", path: "/private/collections/{id}", summary: "Rename or share a collection", request: collectionBody, responses: okResponse(model.Collection{})},
+	{method: "DELETE", path: "/private/collections/{id}", summary: "Delete a collection", responses: noContentResponse()},
+	{method: "POST", path: "/private/collections/{id}/posts", summary: "Add a post to a collection", request: collectionPostBody, responses: okResponse(model.Collection{})},
+	{method: "DELETE", path: "/private/collections/{id}/posts/{post_id}", summary: "Remove a post from a collection", responses: noContentResponse()},
+	{method: "PUT", path: "/private/collections/{id}/order", summary: "Reorder a collection's posts", request: collectionOrderBody, responses: okResponse(model.Collection{})},
+	{method: "PUT", path: "/private/me/profile", summary: "Update own profile", request: profileBody, responses: okResponse(model.User{})},
+	{method: "POST", path: "/private/me/avatar", summary: "Upload own avatar", form: "avatar", responses: okResponse(model.User{})},
+	{method: "GET", path: "/private/me/export", summary: "Export own data, synchronously for small accounts", responses: []apiResponse{
+		{status: http.StatusOK, contentType: "application/zip"},
+		{status: http.StatusAccepted, body: exportJob{}},
+	}},
+	{method: "GET", path: "/private/me/export/{id}", summary: "Get the status of an export", params: map[string]string{"id": "string"}, responses: okResponse(exportStatusBody)},
+	{method: "GET", path: "/private/me/export/{id}/download", summary: "Download a finished export", params: map[string]string{"id": "string"}, responses: fileResponse("application/zip")},
+	{method: "POST", path: "/private/posts", summary: "Create a post", idempotent: true, request: postCreateBody, responses: createdResponse(model.Post{})},
+	{method: "GET", path: "/private/posts", summary: "List own posts", cached: true, query: []string{"status"}, responses: okResponse(listOf{model.Post{}})},
+	{method: "GET", path: "/private/posts/{id}", summary: "Get an own post", cached: true, responses: okResponse(legacyPostItemBody)},
+	{method: "PUT", path: "/private/posts/{id}", summary: "Replace a post's header and text", ifMatch: true, request: postUpdateBody, responses: okResponse(model.Post{})},
+	{method: "PATCH", path: "/private/posts/{id}", summary: "Edit a post with a JSON Merge Patch", ifMatch: true, request: postEdit{}, mediaType: mergePatchContentType, responses: okResponse(model.Post{})},
+	{method: "DELETE", path: "/private/posts/{id}", summary: "Move a post to the trash", ifMatch: true, responses: okResponse(nil)},
+	{method: "PUT", path: "/private/posts/{id}/status", summary: "Publish, schedule or unpublish a post", ifMatch: true, request: postStatusBody, responses: okResponse(model.Post{})},
+	{method: "POST", path: "/private/posts/{id}/revisions/{rev}/restore", summary: "Restore a post revision", ifMatch: true, params: map[string]string{"rev": "string"}, responses: okResponse(model.Post{})},
+	{method: "POST", path: "/private/posts/{id}/star", summary: "Star a post", responses: []apiResponse{
+		{status: http.StatusCreated, body: model.Star{}},
+		{status: http.StatusAccepted, body: model.Star{}},
+	}},
+	{method: "DELETE", path: "/private/posts/{id}/star", summary: "Unstar a post", responses: []apiResponse{
+		{status: http.StatusOK, body: model.Star{}},
+		{status: http.StatusAccepted, body: model.Star{}},
+	}},
+	{method: "POST", path: "/private/posts/{id}/reports", summary: "Report a post", idempotent: true, request: reportCreateBody, responses: []apiResponse{
+		{status: http.StatusCreated, body: model.Report{}},
+		{status: http.StatusOK, body: model.Report{}},
+	}},
+	{method: "POST", path: "/private/uploads", summary: "Upload an attachment", idempotent: true, form: "file", responses: createdResponse(model.Attachment{})},
+	{method: "GET", path: "/private/reports", summary: "List own reports", responses: okResponse(listOf{model.Report{}})},
+	{method: "GET", path: "/private/notifications", summary: "List own notifications", paged: true, query: []string{"unread"}, responses: okResponse(notificationsBody)},
+	{method: "POST", path: "/private/notifications/read", summary: "Mark notifications read, all of them when no IDs are given", request: notificationsReadBody, responses: okResponse(notificationsMarkedBody)},
+	{method: "POST", path: "/private/notifications/{id}/read", summary: "Mark a notification read", responses: noContentResponse()},
+	{method: "GET", path: "/private/notifications/preferences", summary: "Get notification preferences", responses: okResponse(map[string]bool{})},
+	{method: "PUT", path: "/private/notifications/preferences", summary: "Turn notification types on or off", request: map[string]bool{}, responses: okResponse(map[string]bool{})},
+	{method: "GET", path: "/private/trash", summary: "List own deleted posts", responses: okResponse(listOf{model.Post{}})},
+	{method: "POST", path: "/private/trash/{id}/restore", summary: "Restore a deleted post", responses: okResponse(model.Post{})},
+
+	{method: "GET", path: "/admin/users", summary: "Search users", paged: true, query: []string{"q"}, responses: okResponse(listOf{model.User{}})},
+	{method: "POST", path: "/admin/users/{id}/ban", summary: "Ban a user", responses: noContentResponse()},
+	{method: "DELETE", path: "/admin/users/{id}/ban", summary: "Unban a user", responses: noContentResponse()},
+	{method: "PUT", path: "/admin/users/{id}/role", summary: "Change a user's role", request: roleBody, responses: okResponse(model.User{})},
+	{method: "GET", path: "/admin/reports", summary: "List open reports", paged: true, responses: okResponse(listOf{model.Report{}})},
+	{method: "POST", path: "/admin/reports/{id}/resolve", summary: "Resolve a report and the other open reports on its post", request: reportResolveBody, responses: okResponse(listOf{model.Report{}})},
+	{method: "DELETE", path: "/admin/posts/{id}", summary: "Delete any post", responses: noContentResponse()},
+	{method: "POST", path: "/admin/posts/{id}/hide", summary: "Hide a post", responses: noContentResponse()},
+	{method: "DELETE", path: "/admin/posts/{id}/hide", summary: "Unhide a post", responses: noContentResponse()},
+	{method: "GET", path: "/admin/stats", summary: "Get system statistics", responses: okResponse(model.SystemStats{})},
+	{method: "GET", path: "/admin/audit", summary: "List the audit log", paged: true, responses: okResponse(listOf{model.AuditEntry{}})},
+}
+
+// errorResponses are the shared error responses, keyed by status code.
+var errorResponses = map[int]string{
+	http.StatusBadRequest:            "BadRequest",
+	http.StatusUnauthorized:          "Unauthorized",
+	http.StatusForbidden:             "Forbidden",
+	http.StatusNotFound:              "NotFound",
+	http.StatusConflict:              "Conflict",
+	http.StatusPreconditionFailed:    "PreconditionFailed",
+	http.StatusRequestEntityTooLarge: "RequestEntityTooLarge",
+	http.StatusUnsupportedMediaType:  "UnsupportedMediaType",
+	http.StatusUnprocessableEntity:   "UnprocessableEntity",
+	http.StatusTooManyRequests:       "TooManyRequests",
+	http.StatusInternalServerError:   "InternalServerError",
+}
+
+var pathParamRe = regexp.MustCompile(`{([^}]+)}`)
+
+// openAPISpec builds the OpenAPI 3 document for apiOperations.
+func openAPISpec() map[string]interface{} {
+	g := &schemaGenerator{schemas: map[string]interface{}{}}
+	problemSchema := g.value(problem{})
+
+	paths := map[string]map[string]interface{}{}
+	for _, op := range apiOperations {
+		if paths[op.path] == nil {
+			paths[op.path] = map[string]interface{}{}
+		}
+		paths[op.path][strings.ToLower(op.method)] = g.operation(op)
+	}
+
+	responses := map[string]interface{}{}
+	for code, name := range errorResponses {
+		responses[name] = map[string]interface{}{
+			"description": http.StatusText(code),
+			"content": map[string]interface{}{
+				problemContentType: map[string]interface{}{
+					"schema": problemSchema,
+				},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Booklib API",
+			"version": "1.0.0",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": apiBasePath},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas":   g.schemas,
+			"responses": responses,
+			"securitySchemes": map[string]interface{}{
+				"session": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": sessionName,
+				},
+			},
+		},
+	}
+}
+
+func headerParameter(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":   name,
+		"in":     "header",
+		"schema": map[string]interface{}{"type": "string"},
+	}
+}
+
+func (g *schemaGenerator) operation(op apiOperation) map[string]interface{} {
+	segments := strings.Split(strings.TrimPrefix(op.path, "/"), "/")
+	tag := segments[0]
+	if tag == "private" && len(segments) > 1 {
+		tag = segments[1]
+	}
+
+	parameters := []interface{}{}
+	for _, m := range pathParamRe.FindAllStringSubmatch(op.path, -1) {
+		typ := op.params[m[1]]
+		if typ == "" {
+			typ = "integer"
+		}
+		parameters = append(parameters, map[string]interface{}{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": typ},
+		})
+	}
+
+	query := op.query
+	if op.paged {
+		query = append([]string{"limit", "offset"}, query...)
+	}
+	for _, name := range query {
+		typ := "string"
+		if name == "limit" || name == "offset" {
+			typ = "integer"
+		}
+		parameters = append(parameters, map[string]interface{}{
+			"name":   name,
+			"in":     "query",
+			"schema": map[string]interface{}{"type": typ},
+		})
+	}
+
+	if op.cached {
+		parameters = append(parameters, headerParameter("If-None-Match"))
+	}
+	if op.ifMatch {
+		parameters = append(parameters, headerParameter("If-Match"))
+	}
+	if op.idempotent {
+		parameters = append(parameters, headerParameter(idempotencyKeyHeader))
+	}
+	csrf := requiresSession(op.path) && op.method != "GET"
+	if csrf {
+		p := headerParameter(csrfHeader)
+		p["required"] = true
+		parameters = append(parameters, p)
+	}
+
+	o := map[string]interface{}{
+		"summary":     op.summary,
+		"operationId": strings.ToLower(op.method) + operationName(op.path),
+		"tags":        []string{tag},
+		"parameters":  parameters,
+	}
+
+	switch {
+	case op.request != nil:
+		mediaType := op.mediaType
+		if mediaType == "" {
+			mediaType = "application/json"
+		}
+		o["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				mediaType: map[string]interface{}{"schema": g.value(op.request)},
+			},
+		}
+	case op.form != "":
+		o["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"multipart/form-data": map[string]interface{}{
+					"schema": map[string]interface{}{
+						"type":     "object",
+						"required": []string{op.form},
+						"properties": map[string]interface{}{
+							op.form: map[string]interface{}{"type": "string", "format": "binary"},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	responses := map[string]interface{}{}
+	for _, resp := range op.responses {
+		r := map[string]interface{}{"description": http.StatusText(resp.status)}
+		switch {
+		case resp.body != nil:
+			r["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": g.value(resp.body)},
+			}
+		case resp.contentType != "":
+			r["content"] = map[string]interface{}{
+				resp.contentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}},
+			}
+		}
+		if op.cached || op.ifMatch && op.method != "DELETE" {
+			r["headers"] = map[string]interface{}{
+				"ETag": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			}
+		}
+		responses[strconv.Itoa(resp.status)] = r
+	}
+	if op.cached {
+		responses[strconv.Itoa(http.StatusNotModified)] = map[string]interface{}{"description": http.StatusText(http.StatusNotModified)}
+	}
+
+	errs := []int{http.StatusTooManyRequests, http.StatusInternalServerError}
+	if len(parameters) > 0 || op.request != nil || op.form != "" {
+		errs = append(errs, http.StatusBadRequest, http.StatusUnprocessableEntity)
+	}
+	if op.request != nil && op.method != "DELETE" {
+		errs = append(errs, http.StatusConflict)
+	}
+	if op.request != nil {
+		errs = append(errs, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType)
+	}
+	if op.ifMatch {
+		errs = append(errs, http.StatusPreconditionFailed)
+	}
+	if op.idempotent {
+		errs = append(errs, http.StatusConflict, http.StatusUnprocessableEntity)
+	}
+	if strings.Contains(op.path, "{") {
+		errs = append(errs, http.StatusNotFound)
+	}
+	if requiresSession(op.path) {
+		errs = append(errs, http.StatusUnauthorized)
+		o["security"] = []interface{}{map[string]interface{}{"session": []string{}}}
+	}
+	if strings.HasPrefix(op.path, "/admin/") || csrf {
+		errs = append(errs, http.StatusForbidden)
+	}
+	for _, code := range errs {
+		responses[strconv.Itoa(code)] = map[string]interface{}{"$ref": "#/components/responses/" + errorResponses[code]}
+	}
+	o["responses"] = responses
+
+	return o
+}
+
+func requiresSession(path string) bool {
+	return path == "/ws" || strings.HasPrefix(path, "/private/") || strings.HasPrefix(path, "/admin/")
+}
+
+// operationName turns /private/posts/{id}/star into PrivatePostsIdStar.
+func operationName(path string) string {
+	b := strings.Builder{}
+	upper := true
+	for _, r := range path {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaGenerator derives JSON schemas from Go types through their json
+// tags. Named structs become shared components referenced by $ref.
+type schemaGenerator struct {
+	schemas map[string]interface{}
+}
+
+func (g *schemaGenerator) value(v interface{}) map[string]interface{} {
+	if l, ok := v.(listOf); ok {
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"items": map[string]interface{}{
+					"type":  "array",
+					"items": g.value(l.item),
+				},
+			},
+		}
+	}
+
+	return g.schema(reflect.TypeOf(v))
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := g.schema(t.Elem())
+		if _, ref := s["$ref"]; !ref {
+			s["nullable"] = true
+		}
+		return s
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+
+		name := componentName(t.Name())
+		if _, ok := g.schemas[name]; !ok {
+			// Reserve the name first so self-referencing types terminate.
+			g.schemas[name] = nil
+			g.schemas[name] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (g *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			embedded := g.object(f.Type)
+			for k, v := range embedded["properties"].(map[string]interface{}) {
+				properties[k] = v
+			}
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = g.schema(f.Type)
+	}
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+func componentName(name string) string {
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func (s *server) handleOpenAPI() http.HandlerFunc {
+	spec := openAPISpec()
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		s.respond(w, r, http.StatusOK, spec)
+	}
+}
+
+// docsPage loads Swagger UI from the assets embedded in the binary, so /docs
+// doesn't depend on, or trust, a CDN.
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Booklib API</title>
+  <link rel="stylesheet" href="/docs/assets/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="/docs/assets/swagger-ui-bundle.js"></script>
+  <script>
+    SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui", withCredentials: true });
+  </script>
+</body>
+</html>
+`
+
+// handleDocs serves Swagger UI pointed at /openapi.json.
+func (s *server) handleDocs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, docsPage)
+	}
+}
+
+// handleDocsAssets serves the swagger-ui-dist files of the release go.mod pins.
+func (s *server) handleDocsAssets() http.Handler {
+	return http.StripPrefix("/docs/assets/", http.FileServer(http.FS(swaggerFiles.FS)))
+}
@@ -0,0 +1,58 @@
+package apiserver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPI_DescribesEveryRoute(t *testing.T) {
+	s := newServer(nil, sessions.NewCookieStore([]byte("secret")), nil, NewConfig())
+	paths := openAPISpec()["paths"].(map[string]map[string]interface{})
+
+	routes := map[string]bool{}
+	err := s.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+
+		// The spec describes v1; the unversioned aliases share its route table.
+		if !strings.HasPrefix(path, apiBasePath+"/") {
+			return nil
+		}
+		path = strings.TrimPrefix(path, apiBasePath)
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"GET"}
+		}
+
+		for _, method := range methods {
+			if method == "OPTIONS" {
+				continue
+			}
+
+			method = strings.ToLower(method)
+			routes[method+" "+path] = true
+			_, ok := paths[path][method]
+			assert.Truef(t, ok, "%s %s has no OpenAPI entry", method, path)
+		}
+
+		return nil
+	})
+	assert.NoError(t, err)
+
+	for path, ops := range paths {
+		for method := range ops {
+			assert.Truef(t, routes[method+" "+path], "OpenAPI entry %s %s has no route", method, path)
+		}
+	}
+}
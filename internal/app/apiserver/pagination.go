@@ -0,0 +1,41 @@
+package apiserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const maxPageLimit = 100
+
+var (
+	errInvalidPagination = errors.New("limit and offset must be non-negative integers")
+)
+
+// pageParams reads ?limit= and ?offset= from the query. A zero limit means no limit.
+func pageParams(r *http.Request) (int, int, error) {
+	q := r.URL.Query()
+	limit, offset := 0, 0
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errInvalidPagination
+		}
+		limit = n
+	}
+
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errInvalidPagination
+		}
+		offset = n
+	}
+
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	return limit, offset, nil
+}
@@ -0,0 +1,153 @@
+package apiserver
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/lib/pq"
+	"github.com/zlyaptica/http-rest-api/internal/app/blob"
+	"github.com/zlyaptica/http-rest-api/internal/app/imaging"
+	"github.com/zlyaptica/http-rest-api/internal/app/store"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 error response. Code is a stable machine-readable
+// identifier clients can switch on; Errors maps invalid fields to messages.
+type problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Code      string            `json:"code"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// errorCodes are the stable codes of the errors handlers report by name.
+var errorCodes = map[error]string{
+	errIncorrectEmailOrPassword: "incorrect_credentials",
+	errNotAuthenticated:         "not_authenticated",
+	errNoPermission:             "no_permission",
+	errInvalidAvatarSize:        "invalid_avatar_size",
+	errFollowSelf:               "follow_self",
+	errPostNotStarrable:         "post_not_starrable",
+	errUnsupportedMediaType:     "unsupported_media_type",
+	errPreconditionFailed:       "precondition_failed",
+	errInvalidIdempotencyKey:    "invalid_idempotency_key",
+	errIdempotencyKeyReused:     "idempotency_key_reused",
+	errIdempotencyKeyInFlight:   "idempotency_key_in_flight",
+	errRateLimited:              "rate_limited",
+	errBodyEmpty:                "body_empty",
+	errBodyTooLarge:             "body_too_large",
+	errMalformedJSON:            "malformed_json",
+	errTrailingData:             "trailing_data",
+	errInvalidCSRFToken:         "invalid_csrf_token",
+	errUserBanned:               "user_banned",
+	errInsufficientRole:         "insufficient_role",
+	errReportOwnPost:            "report_own_post",
+	errReportResolved:           "report_resolved",
+	errUnknownNotificationType:  "unknown_notification_type",
+	errOrderMismatch:            "order_mismatch",
+	errInvalidPagination:        "invalid_pagination",
+	errExportNotFound:           "export_not_found",
+	errExportNotReady:           "export_not_ready",
+	errUnsupportedUpload:        "unsupported_upload",
+	imaging.ErrTooLarge:         "image_too_large",
+	errStreamingUnsupported:     "streaming_unsupported",
+	store.ErrRecordNotFound:     "not_found",
+	store.ErrVersionConflict:    "precondition_failed",
+	blob.ErrNotFound:            "not_found",
+}
+
+// newProblem describes err as a problem. The status handlers pass is
+// corrected for errors whose meaning doesn't depend on the handler: missing
+// records are 404, lost version races 412, unique-constraint violations 409
+// and failed validation 422.
+func newProblem(r *http.Request, status int, err error) *problem {
+	var verrs validation.Errors
+	switch {
+	case errors.Is(err, store.ErrRecordNotFound), errors.Is(err, blob.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, store.ErrVersionConflict):
+		status = http.StatusPreconditionFailed
+	case isUniqueViolation(err):
+		status = http.StatusConflict
+	case errors.As(err, &verrs):
+		status = http.StatusUnprocessableEntity
+	}
+
+	p := &problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Code:     errorCode(status, err),
+		Detail:   err.Error(),
+		Instance: r.URL.Path,
+	}
+	if id, ok := r.Context().Value(ctxKeyRequestID).(string); ok {
+		p.RequestID = id
+	}
+
+	switch {
+	case verrs != nil:
+		p.Errors = make(map[string]string)
+		flattenValidation("", verrs, p.Errors)
+		p.Detail = validationDetail(p.Errors)
+	case isUniqueViolation(err):
+		p.Detail = "a record with the same unique value already exists"
+	case status >= http.StatusInternalServerError:
+		// Internal details stay in the log.
+		p.Detail = ""
+	}
+
+	return p
+}
+
+func errorCode(status int, err error) string {
+	for e, code := range errorCodes {
+		if errors.Is(err, e) {
+			return code
+		}
+	}
+
+	switch status {
+	case http.StatusUnprocessableEntity:
+		return "validation_failed"
+	case http.StatusInternalServerError:
+		return "internal_error"
+	}
+
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// flattenValidation collects nested validation errors under dotted field names.
+func flattenValidation(prefix string, errs validation.Errors, out map[string]string) {
+	for field, err := range errs {
+		if nested, ok := err.(validation.Errors); ok {
+			flattenValidation(prefix+field+".", nested, out)
+			continue
+		}
+
+		out[prefix+field] = err.Error()
+	}
+}
+
+func validationDetail(fields map[string]string) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return "invalid fields: " + strings.Join(names, ", ")
+}
@@ -0,0 +1,281 @@
+package apiserver
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+// RateLimit allows Rate requests per second on average, in bursts of up to Burst.
+// A zero rate turns limiting off.
+type RateLimit struct {
+	Rate  float64 `toml:"rate"`
+	Burst int     `toml:"burst"`
+}
+
+// rateLimitBackend keeps the token buckets. take spends a token from the
+// bucket under key, creating it full if needed, and returns the bucket as
+// left by the request.
+type rateLimitBackend interface {
+	take(key string, limit RateLimit, now time.Time) (*tokenBucket, bool, error)
+	sweep(now time.Time) error
+}
+
+// rateLimiter limits requests per client: the user when signed in, the
+// client address otherwise. Routes listed in rate_limits get a bucket of
+// their own; every other route draws from the client's shared bucket.
+type rateLimiter struct {
+	backend  rateLimitBackend
+	fallback RateLimit
+	routes   map[string]RateLimit
+	trusted  []*net.IPNet
+}
+
+func newRateLimiter(config *Config, backend rateLimitBackend) (*rateLimiter, error) {
+	l := &rateLimiter{
+		backend:  backend,
+		fallback: config.RateLimit,
+		routes:   config.RateLimits,
+	}
+
+	for _, proxy := range config.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", proxy, err)
+		}
+		l.trusted = append(l.trusted, network)
+	}
+
+	return l, nil
+}
+
+// clientIP returns the address the request came from. X-Forwarded-For is only
+// believed as far back as the chain of trusted proxies goes, so clients can't
+// pick their own address by sending the header themselves.
+func (l *rateLimiter) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !l.isTrusted(host) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+
+		host = hop
+		if !l.isTrusted(hop) {
+			break
+		}
+	}
+
+	return host
+}
+
+func (l *rateLimiter) isTrusted(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range l.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// versionPrefix matches the /v<N> a versioned route template starts with.
+var versionPrefix = regexp.MustCompile(`^/v[0-9]+/`)
+
+// routeKey names the matched route the way rate_limits does, e.g.
+// "POST /sessions". The version prefix is dropped so every version and the
+// unversioned aliases share limits. It's read off the template rather than
+// apiVersion(r), which router-level middleware runs too early to see.
+func routeKey(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+
+	return r.Method + " " + versionPrefix.ReplaceAllString(tmpl, "/")
+}
+
+// limitRequests answers 429 once the client runs out of requests and reports
+// the state of its bucket in RateLimit-* headers.
+func (s *server) limitRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := s.rateLimiter
+		if l == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := "ip:" + l.clientIP(r)
+		if u, ok := r.Context().Value(ctxKeyUser).(*model.User); ok && u != nil {
+			client = "user:" + strconv.Itoa(u.ID)
+		}
+
+		route := routeKey(r)
+		limit, ok := l.routes[route]
+		if !ok {
+			route, limit = "*", l.fallback
+		}
+
+		if limit.Rate <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bucket, allowed, err := l.backend.take(client+" "+route, limit, time.Now())
+		if err != nil {
+			// A broken limiter shouldn't take the API down with it.
+			s.logger.Errorf("rate limiting %s: %v", client, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(bucket.remaining()))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(bucket.wait(float64(limit.Burst)))))
+
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(bucket.wait(1))))
+			s.error(w, r, http.StatusTooManyRequests, errRateLimited)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) sweepRateLimits() {
+	if err := s.rateLimiter.backend.sweep(time.Now()); err != nil {
+		s.logger.Errorf("sweeping rate limits: %v", err)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// memoryRateLimitBackend keeps buckets in the process, so each instance
+// enforces its own limits.
+type memoryRateLimitBackend struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newMemoryRateLimitBackend() *memoryRateLimitBackend {
+	return &memoryRateLimitBackend{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+func (m *memoryRateLimitBackend) take(key string, limit RateLimit, now time.Time) (*tokenBucket, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = newTokenBucket(limit.Rate, float64(limit.Burst))
+		m.buckets[key] = b
+	}
+
+	allowed := b.allow(now)
+	snapshot := *b
+	return &snapshot, allowed, nil
+}
+
+// sweep drops buckets that have refilled; they'd be recreated full anyway.
+func (m *memoryRateLimitBackend) sweep(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, b := range m.buckets {
+		if !b.fullAt().After(now) {
+			delete(m.buckets, key)
+		}
+	}
+
+	return nil
+}
+
+// pgRateLimitBackend keeps buckets in Postgres so every instance draws from
+// the same ones. Bucket times come from the database clock.
+type pgRateLimitBackend struct {
+	db *sqlx.DB
+}
+
+func (p *pgRateLimitBackend) take(key string, limit RateLimit, now time.Time) (*tokenBucket, bool, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"INSERT INTO rate_limits (key, tokens, updated_at, full_at) VALUES ($1, $2, now(), now()) ON CONFLICT (key) DO NOTHING",
+		key,
+		limit.Burst,
+	); err != nil {
+		return nil, false, err
+	}
+
+	b := newTokenBucket(limit.Rate, float64(limit.Burst))
+	if err := tx.QueryRow(
+		"SELECT tokens, updated_at, now() FROM rate_limits WHERE key = $1 FOR UPDATE",
+		key,
+	).Scan(&b.tokens, &b.last, &now); err != nil {
+		return nil, false, err
+	}
+
+	allowed := b.allow(now)
+	if _, err := tx.Exec(
+		"UPDATE rate_limits SET (tokens, updated_at, full_at) = ($1, $2, $3) WHERE key = $4",
+		b.tokens,
+		b.last,
+		b.fullAt(),
+		key,
+	); err != nil {
+		return nil, false, err
+	}
+
+	return b, allowed, tx.Commit()
+}
+
+func (p *pgRateLimitBackend) sweep(now time.Time) error {
+	_, err := p.db.Exec("DELETE FROM rate_limits WHERE full_at <= now()")
+	return err
+}
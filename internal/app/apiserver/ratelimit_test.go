@@ -0,0 +1,176 @@
+package apiserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_ClientIP(t *testing.T) {
+	config := NewConfig()
+	config.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.1", "2001:db8::1"}
+	l, err := newRateLimiter(config, newMemoryRateLimitBackend())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.7:4321",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "untrusted peer can't forward",
+			remoteAddr: "203.0.113.7:4321",
+			forwarded:  []string{"198.51.100.1"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.1.2.3:4321",
+			forwarded:  []string{"198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "spoofed hops before the client are ignored",
+			remoteAddr: "10.1.2.3:4321",
+			forwarded:  []string{"6.6.6.6, 198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "chain of trusted proxies",
+			remoteAddr: "10.1.2.3:4321",
+			forwarded:  []string{"198.51.100.1, 192.0.2.1, 10.9.9.9"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "repeated headers",
+			remoteAddr: "10.1.2.3:4321",
+			forwarded:  []string{"6.6.6.6, 198.51.100.1", "10.9.9.9"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "only trusted hops",
+			remoteAddr: "10.1.2.3:4321",
+			forwarded:  []string{"10.9.9.9"},
+			want:       "10.9.9.9",
+		},
+		{
+			name:       "empty hops skipped",
+			remoteAddr: "10.1.2.3:4321",
+			forwarded:  []string{"198.51.100.1, , "},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "ipv6 proxy",
+			remoteAddr: "[2001:db8::1]:4321",
+			forwarded:  []string{"2001:db8::42"},
+			want:       "2001:db8::42",
+		},
+		{
+			name:       "garbage hop stops the walk",
+			remoteAddr: "10.1.2.3:4321",
+			forwarded:  []string{"198.51.100.1, not-an-ip"},
+			want:       "not-an-ip",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			for _, v := range tc.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			assert.Equal(t, tc.want, l.clientIP(r))
+		})
+	}
+}
+
+func TestNewRateLimiter_InvalidProxy(t *testing.T) {
+	config := NewConfig()
+	config.TrustedProxies = []string{"10.0.0.0/33"}
+	_, err := newRateLimiter(config, newMemoryRateLimitBackend())
+	assert.Error(t, err)
+}
+
+func TestRouteKey(t *testing.T) {
+	router := mux.NewRouter()
+	var got string
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = routeKey(r)
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	noop := func(http.ResponseWriter, *http.Request) {}
+	for _, prefix := range []string{"/v1", "/v2"} {
+		api := router.PathPrefix(prefix).Subrouter()
+		api.HandleFunc("/private/posts/{id}", noop).Methods("PUT")
+	}
+	router.HandleFunc("/private/posts/{id}", noop).Methods("PUT")
+	router.HandleFunc("/videos", noop).Methods("GET")
+
+	testCases := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{method: "PUT", path: "/v1/private/posts/1", want: "PUT /private/posts/{id}"},
+		{method: "PUT", path: "/v2/private/posts/1", want: "PUT /private/posts/{id}"},
+		{method: "PUT", path: "/private/posts/1", want: "PUT /private/posts/{id}"},
+		{method: "GET", path: "/videos", want: "GET /videos"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil))
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestServer_LimitRequests(t *testing.T) {
+	config := NewConfig()
+	config.RateLimit = RateLimit{Rate: 1.0 / 60, Burst: 2}
+	config.RateLimits = map[string]RateLimit{}
+	s := newServer(nil, sessions.NewCookieStore([]byte("secret")), nil, config)
+	s.rateLimiter, _ = newRateLimiter(config, newMemoryRateLimitBackend())
+
+	handler := s.limitRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := get("203.0.113.7:1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+
+	rec = get("203.0.113.7:2")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	rec = get("203.0.113.7:3")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	rec = get("198.51.100.1:1")
+	assert.Equal(t, http.StatusOK, rec.Code, "other clients have buckets of their own")
+}
@@ -0,0 +1,69 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+const eventsChannel = "booklib_events"
+
+// pgRelay fans hub events out to every instance through Postgres
+// LISTEN/NOTIFY. Notifications sent while the listener is reconnecting are lost.
+type pgRelay struct {
+	db       *sqlx.DB
+	listener *pq.Listener
+	hub      *hub
+	logger   *logrus.Logger
+}
+
+func newPGRelay(db *sqlx.DB, databaseURL string, h *hub, logger *logrus.Logger) (*pgRelay, error) {
+	r := &pgRelay{
+		db:     db,
+		hub:    h,
+		logger: logger,
+	}
+	r.listener = pq.NewListener(databaseURL, time.Second, time.Minute, r.logListenerEvent)
+	if err := r.listener.Listen(eventsChannel); err != nil {
+		r.listener.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *pgRelay) publish(e *event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec("SELECT pg_notify($1, $2)", eventsChannel, string(payload))
+	return err
+}
+
+func (r *pgRelay) run() {
+	for n := range r.listener.Notify {
+		// A nil notification means the connection was re-established.
+		if n == nil {
+			continue
+		}
+
+		e := &event{}
+		if err := json.Unmarshal([]byte(n.Extra), e); err != nil {
+			r.logger.Errorf("decoding relayed event: %v", err)
+			continue
+		}
+
+		r.hub.deliver(e)
+	}
+}
+
+func (r *pgRelay) logListenerEvent(ev pq.ListenerEventType, err error) {
+	if err != nil {
+		r.logger.Warnf("events listener: %v", err)
+	}
+}
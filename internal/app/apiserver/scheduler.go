@@ -0,0 +1,131 @@
+package apiserver
+
+import "time"
+
+// startJobs launches the server's background workers and periodic jobs.
+func (s *server) startJobs() {
+	go s.notifier.run()
+	go s.renderMissingHTML()
+	go s.every(time.Duration(s.config.PublishInterval)*time.Second, s.publishDue)
+	go s.every(time.Hour, s.collectOrphanedAttachments)
+	go s.every(time.Hour, s.purgeTrash)
+	go s.every(time.Hour, s.purgeExports)
+	go s.every(time.Hour, s.purgeIdempotencyKeys)
+	if s.rateLimiter != nil {
+		go s.every(time.Minute, s.sweepRateLimits)
+	}
+}
+
+func (s *server) every(interval time.Duration, job func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		job()
+	}
+}
+
+// publishDue publishes scheduled posts whose time has come.
+func (s *server) publishDue() {
+	ids, err := s.store.Post().PublishDue(time.Now())
+	if err != nil {
+		s.logger.Errorf("publishing scheduled posts: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		post, err := s.store.Post().Find(id)
+		if err != nil {
+			s.logger.Errorf("loading published post %d: %v", id, err)
+			continue
+		}
+
+		s.postPublished(post)
+	}
+
+	if len(ids) > 0 {
+		s.logger.Infof("published %d scheduled posts", len(ids))
+	}
+}
+
+// renderMissingHTML backfills the HTML of posts written before Markdown
+// rendering existed, a batch at a time.
+func (s *server) renderMissingHTML() {
+	total, last := 0, 0
+	for {
+		ids, err := s.store.Post().RenderMissingHTML(last, 100)
+		if err != nil {
+			s.logger.Errorf("rendering legacy posts: %v", err)
+			return
+		}
+
+		if len(ids) == 0 {
+			break
+		}
+
+		total += len(ids)
+		last = ids[len(ids)-1]
+	}
+
+	if total > 0 {
+		s.logger.Infof("rendered HTML for %d legacy posts", total)
+	}
+}
+
+// trashCutoff is the deletion time before which trashed posts can no longer be restored.
+func (s *server) trashCutoff() time.Time {
+	return time.Now().Add(-time.Duration(s.config.TrashRetention) * 24 * time.Hour)
+}
+
+// purgeTrash permanently removes posts whose retention window has passed, along with their attachments.
+func (s *server) purgeTrash() {
+	ids, err := s.store.Post().FindPurgeable(s.trashCutoff())
+	if err != nil {
+		s.logger.Errorf("finding posts to purge: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		attachments, err := s.store.Attachment().FindByPost(id)
+		if err != nil {
+			s.logger.Errorf("finding attachments of post %d: %v", id, err)
+			continue
+		}
+
+		if err := s.store.Post().Purge(id); err != nil {
+			s.logger.Errorf("purging post %d: %v", id, err)
+			continue
+		}
+
+		s.deleteAttachments(attachments)
+	}
+
+	if len(ids) > 0 {
+		s.logger.Infof("purged %d deleted posts", len(ids))
+	}
+}
+
+// purgeExports deletes background exports older than the export retention.
+func (s *server) purgeExports() {
+	n, err := s.exporter.sweep(time.Now().Add(-time.Duration(s.config.ExportRetention) * time.Hour))
+	if err != nil {
+		s.logger.Errorf("purging exports: %v", err)
+	}
+
+	if n > 0 {
+		s.logger.Infof("purged %d expired exports", n)
+	}
+}
+
+// purgeIdempotencyKeys forgets stored responses older than the idempotency TTL.
+func (s *server) purgeIdempotencyKeys() {
+	n, err := s.store.Idempotency().DeleteExpired(s.idempotencyCutoff())
+	if err != nil {
+		s.logger.Errorf("purging idempotency keys: %v", err)
+		return
+	}
+
+	if n > 0 {
+		s.logger.Infof("purged %d expired idempotency keys", n)
+	}
+}
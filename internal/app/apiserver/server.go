@@ -4,28 +4,65 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"strconv"
 	"time"
 
+	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
 	"github.com/sirupsen/logrus"
+	"github.com/zlyaptica/http-rest-api/internal/app/blob"
+	"github.com/zlyaptica/http-rest-api/internal/app/diff"
+	"github.com/zlyaptica/http-rest-api/internal/app/imaging"
+	"github.com/zlyaptica/http-rest-api/internal/app/mergepatch"
 	"github.com/zlyaptica/http-rest-api/internal/app/model"
 	"github.com/zlyaptica/http-rest-api/internal/app/store"
 )
 
+const (
+	revisionCurrent       = "current"
+	allowedOrigin         = "http://localhost:3000"
+	mergePatchContentType = "application/merge-patch+json"
+)
+
 const (
 	sessionName        = "booklib"
 	ctxKeyUser  ctxKey = iota
 	ctxKeyRequestID
+	ctxKeyAPIVersion
 )
 
 var (
 	errIncorrectEmailOrPassword = errors.New("incorrect email or password")
 	errNotAuthenticated         = errors.New("not authenticated")
 	errNoPermission             = errors.New("no permission")
+	errInvalidAvatarSize        = errors.New("invalid avatar size")
+	errFollowSelf               = errors.New("cannot follow yourself")
+	errPostNotStarrable         = errors.New("only published posts can be starred")
+	errUserBanned               = errors.New("user is banned")
+	errInsufficientRole         = errors.New("insufficient role")
+	errReportOwnPost            = errors.New("cannot report your own post")
+	errReportResolved           = errors.New("report is already resolved")
+	errUnknownNotificationType  = errors.New("unknown notification type")
+	errFieldMissing             = errors.New("is required")
+	errUnsupportedMediaType     = errors.New("unsupported media type")
+	errPreconditionFailed       = errors.New("the post has changed since it was read")
+	errInvalidIdempotencyKey    = errors.New("idempotency key is too long")
+	errIdempotencyKeyReused     = errors.New("idempotency key was used for a different request")
+	errIdempotencyKeyInFlight   = errors.New("a request with this idempotency key is still in progress")
+	errBodyEmpty                = errors.New("request body is empty")
+	errBodyTooLarge             = errors.New("request body is too large")
+	errMalformedJSON            = errors.New("malformed JSON")
+	errTrailingData             = errors.New("unexpected data after the JSON value")
+	errUnknownField             = errors.New("is not a known field")
+	errInvalidCSRFToken         = errors.New("missing or invalid CSRF token")
+	errOrderMismatch            = errors.New("order must list every post in the collection exactly once")
 )
 
 type ctxKey int8
@@ -35,14 +72,27 @@ type server struct {
 	logger       *logrus.Logger
 	store        store.Store
 	sessionStore sessions.Store
+	blobs        blob.Storage
+	config       *Config
+	exporter     *exporter
+	notifier     *notifier
+	hub          *hub
+	rateLimiter  *rateLimiter
 }
 
-func newServer(store store.Store, sessionStore sessions.Store) *server {
+func newServer(store store.Store, sessionStore sessions.Store, blobs blob.Storage, config *Config) *server {
+	logger := logrus.New()
+	h := newHub(logger)
 	s := &server{
 		router:       mux.NewRouter(),
-		logger:       logrus.New(),
+		logger:       logger,
 		store:        store,
 		sessionStore: sessionStore,
+		blobs:        blobs,
+		config:       config,
+		exporter:     newExporter(config.ExportDir),
+		notifier:     newNotifier(store, logger, h),
+		hub:          h,
 	}
 
 	s.configureRouter()
@@ -59,31 +109,116 @@ func (s *server) configureRouter() {
 	s.router.Use(s.logRequest)
 	s.router.Use(s.setCORS)
 	s.router.Use(s.authenticateUser)
-	s.router.HandleFunc("/users", s.handleUsersCreate()).Methods("POST", "OPTIONS")
-	s.router.HandleFunc("/sessions", s.handleSessionsCreate()).Methods("POST", "OPTIONS")
+	s.router.Use(s.protectCSRF)
+	s.router.Use(s.limitRequests)
+	s.router.HandleFunc("/openapi.json", s.handleOpenAPI()).Methods("GET")
+	s.router.HandleFunc("/docs", s.handleDocs()).Methods("GET")
+	s.router.PathPrefix("/docs/assets/").Handler(s.handleDocsAssets()).Methods("GET")
+
+	for _, version := range apiVersions {
+		api := s.router.PathPrefix(fmt.Sprintf("/v%d", version)).Subrouter()
+		api.Use(setAPIVersion(version))
+		s.configureAPI(api)
+	}
 
-	s.router.HandleFunc("/posts", s.handlePostsGet()).Methods("GET")
-	s.router.HandleFunc("/user/{id}", s.handleGetUserByID()).Methods("GET")
-	s.router.HandleFunc("/user/{id}/posts", s.handlePostsGetByUserID()).Methods("GET")
+	legacy := s.router.NewRoute().Subrouter()
+	legacy.Use(setAPIVersion(1))
+	legacy.Use(s.deprecateUnversioned)
+	s.configureAPI(legacy)
+}
 
-	private := s.router.PathPrefix("/private").Subrouter()
+// configureAPI registers the versioned routes on router.
+func (s *server) configureAPI(router *mux.Router) {
+	router.HandleFunc("/users", s.handleUsersCreate()).Methods("POST", "OPTIONS")
+	router.HandleFunc("/sessions", s.handleSessionsCreate()).Methods("POST", "OPTIONS")
+
+	router.HandleFunc("/posts", s.handlePostsGet()).Methods("GET")
+	router.HandleFunc("/posts/{ref}", s.handlePostGetByRef()).Methods("GET")
+	router.HandleFunc("/posts/{id}/revisions", s.handleRevisionsGet()).Methods("GET")
+	router.HandleFunc("/posts/{id}/revisions/diff", s.handleRevisionsDiff()).Methods("GET")
+	router.HandleFunc("/user/{id}", s.handleGetUserByID()).Methods("GET")
+	router.HandleFunc("/user/{id}/posts", s.handlePostsGetByUserID()).Methods("GET")
+	router.HandleFunc("/user/{id}/avatar/{size}", s.handleAvatarGet()).Methods("GET")
+	router.HandleFunc("/uploads/{id}", s.handleUploadGet(false)).Methods("GET")
+	router.HandleFunc("/uploads/{id}/thumb", s.handleUploadGet(true)).Methods("GET")
+	router.HandleFunc("/collections/{slug}", s.handleSharedCollectionGet()).Methods("GET")
+	router.HandleFunc("/user/{id}/followers", s.handleFollowersGet()).Methods("GET")
+	router.HandleFunc("/user/{id}/following", s.handleFollowingGet()).Methods("GET")
+	router.HandleFunc("/feed.rss", s.handleSiteFeed(feedRSS)).Methods("GET")
+	router.HandleFunc("/feed.atom", s.handleSiteFeed(feedAtom)).Methods("GET")
+	router.HandleFunc("/user/{id}/feed.rss", s.handleUserFeed(feedRSS)).Methods("GET")
+	router.HandleFunc("/user/{id}/feed.atom", s.handleUserFeed(feedAtom)).Methods("GET")
+	router.HandleFunc("/stream", s.handleStream()).Methods("GET")
+	router.Handle("/ws", s.authorizeUser(s.handleWebSocket())).Methods("GET")
+
+	private := router.PathPrefix("/private").Subrouter()
 	private.Use(s.authorizeUser)
 
 	private.HandleFunc("/whoami", s.handleWhoami())
-	private.HandleFunc("/posts", s.handlePostsCreate()).Methods("POST", "OPTIONS")
+	private.HandleFunc("/csrf-token", s.handleCSRFToken()).Methods("GET", "OPTIONS")
+	private.HandleFunc("/feed", s.handleFeedGet()).Methods("GET", "OPTIONS")
+	private.HandleFunc("/user/{id}/follow", s.handleFollow()).Methods("POST", "OPTIONS")
+	private.HandleFunc("/user/{id}/follow", s.handleUnfollow()).Methods("DELETE", "OPTIONS")
+	private.Handle("/collections", s.idempotent(s.handleCollectionsCreate())).Methods("POST", "OPTIONS")
+	private.HandleFunc("/collections", s.handleCollectionsGet()).Methods("GET", "OPTIONS")
+	private.HandleFunc("/collections/{id}", s.handleCollectionGet()).Methods("GET", "OPTIONS")
+	private.HandleFunc("/collections/{id}", s.handleCollectionUpdate()).Methods("PUT", "OPTIONS")
+	private.HandleFunc("/collections/{id}", s.handleCollectionDelete()).Methods("DELETE", "OPTIONS")
+	private.HandleFunc("/collections/{id}/posts", s.handleCollectionAddPost()).Methods("POST", "OPTIONS")
+	private.HandleFunc("/collections/{id}/posts/{post_id}", s.handleCollectionRemovePost()).Methods("DELETE", "OPTIONS")
+	private.HandleFunc("/collections/{id}/order", s.handleCollectionReorder()).Methods("PUT", "OPTIONS")
+	private.HandleFunc("/me/profile", s.handleProfileUpdate()).Methods("PUT", "OPTIONS")
+	private.HandleFunc("/me/avatar", s.handleAvatarUpload()).Methods("POST", "OPTIONS")
+	private.HandleFunc("/me/export", s.handleExportCreate()).Methods("GET", "OPTIONS")
+	private.HandleFunc("/me/export/{id}", s.handleExportGet()).Methods("GET", "OPTIONS")
+	private.HandleFunc("/me/export/{id}/download", s.handleExportDownload()).Methods("GET", "OPTIONS")
+	private.Handle("/posts", s.idempotent(s.handlePostsCreate())).Methods("POST", "OPTIONS")
+	private.HandleFunc("/posts", s.handleOwnPostsGet()).Methods("GET", "OPTIONS")
+	private.Handle("/uploads", s.idempotent(s.handleUploadCreate())).Methods("POST", "OPTIONS")
+	private.Handle("/posts/{id}/reports", s.idempotent(s.handleReportCreate())).Methods("POST", "OPTIONS")
+	private.HandleFunc("/reports", s.handleOwnReportsGet()).Methods("GET", "OPTIONS")
+	private.HandleFunc("/notifications", s.handleNotificationsGet()).Methods("GET", "OPTIONS")
+	private.HandleFunc("/notifications/read", s.handleNotificationsRead()).Methods("POST", "OPTIONS")
+	private.HandleFunc("/notifications/{id}/read", s.handleNotificationRead()).Methods("POST", "OPTIONS")
+	private.HandleFunc("/notifications/preferences", s.handleNotificationPreferencesGet()).Methods("GET", "OPTIONS")
+	private.HandleFunc("/notifications/preferences", s.handleNotificationPreferencesUpdate()).Methods("PUT", "OPTIONS")
+	private.HandleFunc("/trash", s.handleTrashGet()).Methods("GET", "OPTIONS")
+	private.HandleFunc("/trash/{id}/restore", s.handleTrashRestore()).Methods("POST", "OPTIONS")
 	private.HandleFunc("/posts/{id}", s.handlePostGet()).Methods("GET", "OPTIONS")
 	private.HandleFunc("/posts/{id}", s.handlePostDelete()).Methods("DELETE", "OPTIONS")
 	private.HandleFunc("/posts/{id}", s.handlePostUpdate()).Methods("PUT", "OPTIONS")
+	private.HandleFunc("/posts/{id}", s.handlePostPatch()).Methods("PATCH", "OPTIONS")
+	private.HandleFunc("/posts/{id}/revisions/{rev}/restore", s.handleRevisionRestore()).Methods("POST", "OPTIONS")
+	private.HandleFunc("/posts/{id}/status", s.handlePostStatusUpdate()).Methods("PUT", "OPTIONS")
 
 	private.HandleFunc("/posts/{id}/star", s.handleStarGive()).Methods("POST", "OPTIONS")
 	private.HandleFunc("/posts/{id}/star", s.handleStarTake()).Methods("DELETE", "OPTIONS")
+
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(s.authorizeUser)
+	admin.Use(s.requireRole(model.RoleModerator))
+
+	admin.HandleFunc("/users", s.handleAdminUsersGet()).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/users/{id}/ban", s.handleAdminBan(true)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/users/{id}/ban", s.handleAdminBan(false)).Methods("DELETE", "OPTIONS")
+	admin.HandleFunc("/reports", s.handleAdminReportsGet()).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/reports/{id}/resolve", s.handleAdminReportResolve()).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/posts/{id}", s.handleAdminPostDelete()).Methods("DELETE", "OPTIONS")
+	admin.HandleFunc("/posts/{id}/hide", s.handleAdminPostHide(true)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/posts/{id}/hide", s.handleAdminPostHide(false)).Methods("DELETE", "OPTIONS")
+
+	adminOnly := s.requireRole(model.RoleAdmin)
+	admin.Handle("/users/{id}/role", adminOnly(s.handleAdminRoleSet())).Methods("PUT", "OPTIONS")
+	admin.Handle("/stats", adminOnly(s.handleAdminStats())).Methods("GET", "OPTIONS")
+	admin.Handle("/audit", adminOnly(s.handleAdminAuditGet())).Methods("GET", "OPTIONS")
 }
 
 func (s *server) setCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, PUT, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Idempotency-Key, If-Match, If-None-Match, X-CSRF-Token")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After, X-CSRF-Token")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, PUT, PATCH, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		if r.Method == "OPTIONS" {
@@ -136,6 +271,13 @@ func (s *server) logRequest(next http.Handler) http.Handler {
 
 func (s *server) authenticateUser(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Bearer requests never fall back to the cookie session; protectCSRF
+		// relies on that to let them through unchecked.
+		if hasBearerToken(r) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyUser, nil)))
+			return
+		}
+
 		session, err := s.sessionStore.Get(r, sessionName)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
@@ -149,7 +291,7 @@ func (s *server) authenticateUser(next http.Handler) http.Handler {
 		}
 
 		u, err := s.store.User().Find(id.(int))
-		if err != nil {
+		if err != nil || u.IsBanned() {
 			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyUser, nil)))
 			return
 		}
@@ -170,6 +312,22 @@ func (s *server) authorizeUser(next http.Handler) http.Handler {
 	})
 }
 
+// requireRole lets through only authorized users with at least the given role.
+// It must run after authorizeUser.
+func (s *server) requireRole(role string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u := r.Context().Value(ctxKeyUser).(*model.User)
+			if !u.HasRole(role) {
+				s.error(w, r, http.StatusForbidden, errInsufficientRole)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (s *server) handleUsersCreate() http.HandlerFunc {
 	type request struct {
 		Username string `json:"username"`
@@ -179,8 +337,7 @@ func (s *server) handleUsersCreate() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := &request{}
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			s.error(w, r, http.StatusBadRequest, err)
+		if !s.decodeJSON(w, r, req) {
 			return
 		}
 
@@ -209,8 +366,7 @@ func (s *server) handleSessionsCreate() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := &request{}
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			s.error(w, r, http.StatusBadRequest, err)
+		if !s.decodeJSON(w, r, req) {
 			return
 		}
 
@@ -220,6 +376,11 @@ func (s *server) handleSessionsCreate() http.HandlerFunc {
 			return
 		}
 
+		if u.IsBanned() {
+			s.error(w, r, http.StatusForbidden, errUserBanned)
+			return
+		}
+
 		session, err := s.sessionStore.Get(r, sessionName)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
@@ -230,40 +391,67 @@ func (s *server) handleSessionsCreate() http.HandlerFunc {
 			session.Options.MaxAge = 0
 		}
 		session.Values["user_id"] = u.ID
+
+		// A new sign-in gets a fresh token so one planted before it is useless.
+		delete(session.Values, csrfSessionKey)
+		token, err := csrfToken(session)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
 		if err := s.sessionStore.Save(r, w, session); err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		s.respond(w, r, http.StatusOK, nil)
+		w.Header().Set(csrfHeader, token)
+		s.respond(w, r, http.StatusOK, &csrfTokenResponse{CSRFToken: token})
 	}
 }
 
 func (s *server) handlePostsCreate() http.HandlerFunc {
 	type request struct {
-		Header   string `json:"header"`
-		TextPost string `json:"text_post"`
+		Header        string     `json:"header"`
+		TextPost      string     `json:"text_post"`
+		Status        string     `json:"status"`
+		PublishAt     *time.Time `json:"publish_at"`
+		AttachmentIDs []int      `json:"attachment_ids"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := &request{}
 		author := r.Context().Value(ctxKeyUser).(*model.User)
 
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			s.error(w, r, http.StatusBadRequest, err)
+		if !s.decodeJSON(w, r, req) {
 			return
 		}
 
 		p := &model.Post{
-			Header:   req.Header,
-			TextPost: req.TextPost,
-			Author:   author,
+			Header:    req.Header,
+			TextPost:  req.TextPost,
+			Author:    author,
+			Status:    req.Status,
+			PublishAt: req.PublishAt,
+		}
+		if p.Status == "" {
+			p.Status = model.PostStatusPublished
 		}
-		if err := s.store.Post().Create(p); err != nil {
+		if err := s.store.Post().Create(p, req.AttachmentIDs); err != nil {
 			s.error(w, r, http.StatusUnprocessableEntity, err)
 			return
 		}
 
+		if err := s.loadAttachments(p); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if p.IsPublished() {
+			s.postPublished(p)
+		}
+
+		w.Header().Set("ETag", postETag(p))
 		s.respond(w, r, http.StatusCreated, p)
 	}
 }
@@ -289,237 +477,2145 @@ func (s *server) handlePostDelete() http.HandlerFunc {
 			s.error(w, r, http.StatusUnauthorized, errNoPermission)
 			return
 		}
-		s.store.Post().Delete(id)
+
+		if !s.checkIfMatch(w, r, post) {
+			return
+		}
+
+		if err := s.store.Post().Delete(id, user.ID, ifMatchVersion(r, post)); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
 	}
 }
 
-func (s *server) handlePostUpdate() http.HandlerFunc {
+func (s *server) handleReportCreate() http.HandlerFunc {
 	type request struct {
-		Header   string `json:"header"`
-		TextPost string `json:"text_post"`
+		Reason  string `json:"reason"`
+		Comment string `json:"comment"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		post, ok := s.visiblePost(w, r)
+		if !ok {
+			return
+		}
+
 		req := &request{}
-		vars := mux.Vars(r)
-		id, err := strconv.Atoi(vars["id"])
-		if err != nil {
-			s.error(w, r, http.StatusUnprocessableEntity, err)
+		if !s.decodeJSON(w, r, req) {
 			return
 		}
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			s.error(w, r, http.StatusBadRequest, err)
+
+		reporter := r.Context().Value(ctxKeyUser).(*model.User)
+		if post.Author.ID == reporter.ID {
+			s.error(w, r, http.StatusUnprocessableEntity, errReportOwnPost)
 			return
 		}
-		user := r.Context().Value(ctxKeyUser).(*model.User)
 
-		post, err := s.store.Post().Find(id)
+		report := &model.Report{
+			PostID:     post.ID,
+			ReporterID: reporter.ID,
+			Reason:     req.Reason,
+			Comment:    req.Comment,
+		}
+		created, err := s.store.Report().Create(report)
 		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+			s.error(w, r, http.StatusUnprocessableEntity, err)
 			return
 		}
 
-		if post.Author.ID != user.ID {
-			s.error(w, r, http.StatusUnauthorized, errNoPermission)
+		if !created {
+			s.respond(w, r, http.StatusOK, report)
 			return
 		}
-		s.store.Post().Update(req.Header, req.TextPost, id)
+
+		if post.HiddenAt == nil {
+			if err := s.hideIfReported(post.ID); err != nil {
+				s.logger.Errorf("auto-hiding post %d: %v", post.ID, err)
+			}
+		}
+
+		s.respond(w, r, http.StatusCreated, report)
 	}
 }
 
-func (s *server) handleStarGive() http.HandlerFunc {
+// hideIfReported hides the post once its open reports reach the configured threshold.
+func (s *server) hideIfReported(postID int) error {
+	if s.config.ReportHideThreshold <= 0 {
+		return nil
+	}
+
+	count, err := s.store.Report().CountOpen(postID)
+	if err != nil {
+		return err
+	}
+
+	if count < s.config.ReportHideThreshold {
+		return nil
+	}
+
+	s.logger.Infof("hiding post %d after %d reports", postID, count)
+	return s.store.Post().HideReported(postID)
+}
+
+func (s *server) handleOwnReportsGet() http.HandlerFunc {
+	type response struct {
+		Items []model.Report `json:"items"`
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		postID, err := strconv.Atoi(vars["id"])
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		reports, err := s.store.Report().FindByReporter(u.ID)
 		if err != nil {
-			s.error(w, r, http.StatusUnprocessableEntity, err)
+			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		starer := r.Context().Value(ctxKeyUser).(*model.User)
+		resp := &response{
+			Items: reports,
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
 
-		isStarred, err := s.store.Post().IsStarredByUser(starer.ID, postID)
+func (s *server) handleNotificationsGet() http.HandlerFunc {
+	type response struct {
+		Items       []model.Notification `json:"items"`
+		UnreadCount int                  `json:"unread_count"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, offset, err := pageParams(r)
 		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
 
-		star := &model.Star{
-			Starer: starer,
-			Post: &model.Post{
-				ID: postID,
-			},
-		}
-
-		if isStarred {
-			star.Post.StarsCount, err = s.store.Post().GetStarsCount(postID)
-			s.respond(w, r, http.StatusAccepted, star)
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		unreadOnly := r.URL.Query().Get("unread") == "true"
+		notifications, err := s.store.Notification().FindByUser(u.ID, unreadOnly, limit, offset)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
-		s.store.Star().Create(star)
-		star.Post.StarsCount, err = s.store.Post().GetStarsCount(postID)
 
+		unread, err := s.store.Notification().CountUnread(u.ID)
 		if err != nil {
-			s.respond(w, r, http.StatusInternalServerError, err)
+			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		star.Post.IsStarred, err = s.store.Post().IsStarredByUser(starer.ID, postID)
+		resp := &response{
+			Items:       notifications,
+			UnreadCount: unread,
+		}
 
-		s.respond(w, r, http.StatusCreated, star)
+		s.respond(w, r, http.StatusOK, resp)
 	}
 }
 
-func (s *server) handleStarTake() http.HandlerFunc {
+func (s *server) handleNotificationRead() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		postID, err := strconv.Atoi(vars["id"])
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
 		if err != nil {
 			s.error(w, r, http.StatusUnprocessableEntity, err)
 			return
 		}
 
-		starer := r.Context().Value(ctxKeyUser).(*model.User)
-
-		isStarred, err := s.store.Post().IsStarredByUser(starer.ID, postID)
-		if err != nil {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		if _, err := s.store.Notification().MarkRead(u.ID, []int{id}); err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		star := &model.Star{
-			Starer: starer,
-			Post: &model.Post{
-				ID: postID,
-			},
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
+
+// handleNotificationsRead marks the listed notifications as read, or all of them when no IDs are given.
+func (s *server) handleNotificationsRead() http.HandlerFunc {
+	type request struct {
+		IDs []int `json:"ids"`
+	}
+	type response struct {
+		Marked int `json:"marked"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &request{}
+		if r.ContentLength != 0 {
+			if !s.decodeJSON(w, r, req) {
+				return
+			}
 		}
 
-		if !isStarred {
-			s.respond(w, r, http.StatusAccepted, star)
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		n, err := s.store.Notification().MarkRead(u.ID, req.IDs)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		s.store.Star().Delete(starer.ID, postID)
-		star.Post.IsStarred, err = s.store.Post().IsStarredByUser(starer.ID, postID)
-		star.Post.StarsCount, err = s.store.Post().GetStarsCount(postID)
-
-		s.respond(w, r, http.StatusOK, star)
+		s.respond(w, r, http.StatusOK, &response{Marked: n})
 	}
 }
 
-func (s *server) handlePostsGet() http.HandlerFunc {
-	type response struct {
-		Items []model.Post `json:"items"`
-	}
+func (s *server) handleNotificationPreferencesGet() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		posts, err := s.store.Post().FindAll()
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		prefs, err := s.store.Notification().Preferences(u.ID)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		u, ok := r.Context().Value(ctxKeyUser).(*model.User)
-		if ok {
-			for i := 0; i < len(posts); i++ {
-				posts[i].IsStarred, err = s.store.Post().IsStarredByUser(u.ID, posts[i].ID)
-				if err != nil {
-					s.error(w, r, http.StatusInternalServerError, err)
-					return
-				}
+		s.respond(w, r, http.StatusOK, prefs)
+	}
+}
 
+func (s *server) handleNotificationPreferencesUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := map[string]bool{}
+		if !s.decodeJSON(w, r, &req) {
+			return
+		}
+
+		for t := range req {
+			if !model.IsNotificationType(t) {
+				s.error(w, r, http.StatusUnprocessableEntity, errUnknownNotificationType)
+				return
 			}
 		}
 
-		resp := &response{
-			Items: posts,
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		for t, enabled := range req {
+			if err := s.store.Notification().SetPreference(u.ID, t, enabled); err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
 		}
 
-		s.respond(w, r, http.StatusOK, resp)
+		prefs, err := s.store.Notification().Preferences(u.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, prefs)
 	}
 }
 
-func (s *server) handlePostGet() http.HandlerFunc {
+func (s *server) handleTrashGet() http.HandlerFunc {
 	type response struct {
-		Item *model.Post `json:"items"`
+		Items []model.Post `json:"items"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		id, err := strconv.Atoi(vars["id"])
-		post, err := s.store.Post().Find(id)
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		posts, err := s.store.Post().FindTrash(u.ID, s.trashCutoff())
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
 		resp := &response{
-			Item: post,
+			Items: posts,
 		}
 
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
 
-func (s *server) handleGetUserByID() http.HandlerFunc {
-	type response struct {
-		User *model.User `json:"user"`
-	}
+func (s *server) handleTrashRestore() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		id, err := strconv.Atoi(vars["id"])
-		user, err := s.store.User().FindByID(id)
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
 		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+			s.error(w, r, http.StatusUnprocessableEntity, err)
 			return
 		}
-		resp := &response{
-			User: user,
+
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		if err := s.store.Post().Restore(id, u.ID, s.trashCutoff()); err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
 		}
 
-		s.respond(w, r, http.StatusOK, resp)
+		post, err := s.store.Post().Find(id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, post)
 	}
 }
 
-func (s *server) handlePostsGetByUserID() http.HandlerFunc {
-	type response struct {
-		Items []model.Post `json:"items"`
+// handlePostUpdate replaces a post's content; header and text_post must both be present.
+func (s *server) handlePostUpdate() http.HandlerFunc {
+	type request struct {
+		Header        *string `json:"header"`
+		TextPost      *string `json:"text_post"`
+		AttachmentIDs []int   `json:"attachment_ids"`
 	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		id, err := strconv.Atoi(vars["id"])
-		posts, err := s.store.Post().FindByAuthor(id)
+		post, ok := s.ownPost(w, r)
+		if !ok {
+			return
+		}
+
+		req := &request{}
+		if !s.decodeJSON(w, r, req) {
+			return
+		}
+
+		missing := validation.Errors{}
+		if req.Header == nil {
+			missing["header"] = errFieldMissing
+		}
+		if req.TextPost == nil {
+			missing["text_post"] = errFieldMissing
+		}
+		if len(missing) > 0 {
+			s.error(w, r, http.StatusUnprocessableEntity, missing)
+			return
+		}
+
+		s.editPost(w, r, post, postEdit{
+			Header:        *req.Header,
+			TextPost:      *req.TextPost,
+			AttachmentIDs: req.AttachmentIDs,
+		})
+	}
+}
+
+// handlePostPatch applies a JSON Merge Patch to the post's editable fields.
+func (s *server) handlePostPatch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		patch, ok := s.readBody(w, r, mergePatchContentType, "application/json")
+		if !ok {
+			return
+		}
+
+		var doc interface{}
+		if err := decodeStrict(patch, &doc); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		post, ok := s.ownPost(w, r)
+		if !ok {
+			return
+		}
+
+		attachments, err := s.store.Attachment().FindByPost(post.ID)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		u, ok := r.Context().Value(ctxKeyUser).(*model.User)
-		if ok {
-			for i := 0; i < len(posts); i++ {
-				posts[i].IsStarred, err = s.store.Post().IsStarredByUser(u.ID, posts[i].ID)
-				if err != nil {
-					s.error(w, r, http.StatusInternalServerError, err)
-					return
-				}
+		attachmentIDs := []int{}
+		for _, a := range attachments {
+			attachmentIDs = append(attachmentIDs, a.ID)
+		}
 
-			}
+		current, err := json.Marshal(&postEdit{
+			Header:        post.Header,
+			TextPost:      post.TextPost,
+			AttachmentIDs: attachmentIDs,
+		})
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
 		}
 
-		resp := &response{
-			Items: posts,
+		merged, err := mergepatch.Apply(current, patch)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
 		}
 
-		s.respond(w, r, http.StatusOK, resp)
+		edit := postEdit{}
+		if err := decodeStrict(merged, &edit); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		s.editPost(w, r, post, edit)
 	}
 }
 
-func (s *server) handleWhoami() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		s.respond(w, r, http.StatusOK, r.Context().Value(ctxKeyUser).(*model.User))
+// postEdit is the editable representation of a post.
+type postEdit struct {
+	Header        string `json:"header"`
+	TextPost      string `json:"text_post"`
+	AttachmentIDs []int  `json:"attachment_ids"`
+}
+
+// editPost validates and saves new content for the post, then responds with it.
+func (s *server) editPost(w http.ResponseWriter, r *http.Request, post *model.Post, edit postEdit) {
+	if !s.checkIfMatch(w, r, post) {
+		return
+	}
+
+	user := r.Context().Value(ctxKeyUser).(*model.User)
+	previous := post.TextPost
+	post.Header = edit.Header
+	post.TextPost = edit.TextPost
+	if err := post.Validate(); err != nil {
+		s.error(w, r, http.StatusUnprocessableEntity, err)
+		return
 	}
+
+	// The edit carries the post's whole attachment set; leaving it out drops them all.
+	attachmentIDs := edit.AttachmentIDs
+	if attachmentIDs == nil {
+		attachmentIDs = []int{}
+	}
+
+	if err := s.store.Post().Update(post, user.ID, attachmentIDs); err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := s.loadAttachments(post); err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if post.IsPublished() {
+		s.notifier.notifyMentions(user, post, post.TextPost, previous)
+	}
+
+	w.Header().Set("ETag", postETag(post))
+	s.respond(w, r, http.StatusOK, post)
 }
 
-func (s *server) error(w http.ResponseWriter, r *http.Request, code int, err error) {
-	s.respond(w, r, code, map[string]string{"error": err.Error()})
+// hasMediaType reports whether the request body is one of the given media types.
+func hasMediaType(r *http.Request, types ...string) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	for _, t := range types {
+		if mediaType == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *server) ownPost(w http.ResponseWriter, r *http.Request) (*model.Post, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		s.error(w, r, http.StatusUnprocessableEntity, err)
+		return nil, false
+	}
+
+	post, err := s.store.Post().Find(id)
+	if err != nil {
+		s.error(w, r, http.StatusNotFound, err)
+		return nil, false
+	}
+
+	u := r.Context().Value(ctxKeyUser).(*model.User)
+	if post.Author.ID != u.ID {
+		s.error(w, r, http.StatusUnauthorized, errNoPermission)
+		return nil, false
+	}
+
+	return post, true
+}
+
+func (s *server) handleOwnPostsGet() http.HandlerFunc {
+	type response struct {
+		Items []model.Post `json:"items"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		posts, err := s.store.Post().FindOwn(u.ID, r.URL.Query().Get("status"))
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err := s.markViewerState(r, posts); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := &response{
+			Items: posts,
+		}
+
+		s.respondCached(w, r, resp)
+	}
+}
+
+func (s *server) handlePostStatusUpdate() http.HandlerFunc {
+	type request struct {
+		Status    string     `json:"status"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &request{}
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		if !s.decodeJSON(w, r, req) {
+			return
+		}
+		user := r.Context().Value(ctxKeyUser).(*model.User)
+
+		post, err := s.store.Post().Find(id)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		if post.Author.ID != user.ID {
+			s.error(w, r, http.StatusUnauthorized, errNoPermission)
+			return
+		}
+
+		if !s.checkIfMatch(w, r, post) {
+			return
+		}
+
+		wasPublished := post.IsPublished()
+		post.Status = req.Status
+		post.PublishAt = req.PublishAt
+		if err := s.store.Post().UpdateStatus(post, ifMatchVersion(r, post)); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		if post.IsPublished() && !wasPublished {
+			s.postPublished(post)
+		}
+
+		w.Header().Set("ETag", postETag(post))
+		s.respond(w, r, http.StatusOK, post)
+	}
+}
+
+// postPublished announces a post that just became public, whether on
+// creation, through the status endpoint or by the scheduler, and notifies the
+// users it mentions. Mentions written while it was a draft notify nobody until then.
+func (s *server) postPublished(p *model.Post) {
+	s.announcePost(p)
+	s.notifier.notifyMentions(p.Author, p, p.TextPost, "")
+}
+
+// handlePostGetByRef resolves a post by numeric ID or slug, redirecting slugs retired by header edits.
+func (s *server) handlePostGetByRef() http.HandlerFunc {
+	type response struct {
+		Item *model.Post `json:"item"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref := mux.Vars(r)["ref"]
+
+		var post *model.Post
+		id, err := strconv.Atoi(ref)
+		if err == nil {
+			post, err = s.store.Post().Find(id)
+		} else {
+			post, err = s.store.Post().FindBySlug(ref)
+			if err == store.ErrRecordNotFound {
+				if id, err := s.store.Post().FindIDByOldSlug(ref); err == nil {
+					if post, err := s.store.Post().Find(id); err == nil && canView(r, post) {
+						http.Redirect(w, r, apiBasePath+"/posts/"+post.Slug, http.StatusMovedPermanently)
+						return
+					}
+				}
+			}
+		}
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		if !canView(r, post) {
+			s.error(w, r, http.StatusNotFound, store.ErrRecordNotFound)
+			return
+		}
+
+		post.StarsCount, err = s.store.Post().GetStarsCount(post.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err := s.loadAttachments(post); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		posts := []model.Post{*post}
+		if err := s.markViewerState(r, posts); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := &response{
+			Item: &posts[0],
+		}
+
+		s.respondCached(w, r, resp)
+	}
+}
+
+func (s *server) handleRevisionsGet() http.HandlerFunc {
+	type response struct {
+		Items []model.Revision `json:"items"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		post, ok := s.visiblePost(w, r)
+		if !ok {
+			return
+		}
+
+		revisions, err := s.store.Revision().FindByPost(post.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := &response{
+			Items: revisions,
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *server) handleRevisionsDiff() http.HandlerFunc {
+	type response struct {
+		From   string      `json:"from"`
+		To     string      `json:"to"`
+		Header []diff.Line `json:"header"`
+		Text   []diff.Line `json:"text_post"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		post, ok := s.visiblePost(w, r)
+		if !ok {
+			return
+		}
+
+		q := r.URL.Query()
+		from, to := q.Get("from"), q.Get("to")
+		if to == "" {
+			to = revisionCurrent
+		}
+
+		a, err := s.revisionContent(post, from)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		b, err := s.revisionContent(post, to)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		resp := &response{
+			From:   from,
+			To:     to,
+			Header: diff.Lines(a.Header, b.Header),
+			Text:   diff.Lines(a.TextPost, b.TextPost),
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *server) handleRevisionRestore() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		user := r.Context().Value(ctxKeyUser).(*model.User)
+
+		post, err := s.store.Post().Find(id)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		if post.Author.ID != user.ID {
+			s.error(w, r, http.StatusUnauthorized, errNoPermission)
+			return
+		}
+
+		if !s.checkIfMatch(w, r, post) {
+			return
+		}
+
+		rev, err := s.revisionContent(post, vars["rev"])
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		// Update only writes over the version loaded above, so an edit made
+		// since checkIfMatch fails with 412 instead of being overwritten.
+		post.Header = rev.Header
+		post.TextPost = rev.TextPost
+		if err := s.store.Post().Update(post, user.ID, nil); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		w.Header().Set("ETag", postETag(post))
+		s.respond(w, r, http.StatusOK, post)
+	}
+}
+
+// visiblePost loads the post from the {id} route variable, hiding unpublished posts from everyone but their author.
+func (s *server) visiblePost(w http.ResponseWriter, r *http.Request) (*model.Post, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		s.error(w, r, http.StatusUnprocessableEntity, err)
+		return nil, false
+	}
+
+	post, err := s.store.Post().Find(id)
+	if err != nil {
+		s.error(w, r, http.StatusNotFound, err)
+		return nil, false
+	}
+
+	if !canView(r, post) {
+		s.error(w, r, http.StatusNotFound, store.ErrRecordNotFound)
+		return nil, false
+	}
+
+	return post, true
+}
+
+// canView reports whether the viewer may see the post: published posts are public unless a moderator hid them,
+// the rest are visible to their author and to moderators.
+func canView(r *http.Request, post *model.Post) bool {
+	u, _ := r.Context().Value(ctxKeyUser).(*model.User)
+	return canViewAs(u, post)
+}
+
+// canViewAs reports whether u, which is nil for anonymous viewers, can see the post.
+func canViewAs(u *model.User, post *model.Post) bool {
+	if post.IsPublished() && post.HiddenAt == nil {
+		return true
+	}
+
+	return u != nil && (u.ID == post.Author.ID || u.HasRole(model.RoleModerator))
+}
+
+// revisionContent resolves a revision ID, or "current" for the live post, to its content.
+func (s *server) revisionContent(post *model.Post, ref string) (*model.Revision, error) {
+	if ref == revisionCurrent {
+		return &model.Revision{
+			PostID:   post.ID,
+			Header:   post.Header,
+			TextPost: post.TextPost,
+		}, nil
+	}
+
+	id, err := strconv.Atoi(ref)
+	if err != nil {
+		return nil, store.ErrRecordNotFound
+	}
+
+	rev, err := s.store.Revision().Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if rev.PostID != post.ID {
+		return nil, store.ErrRecordNotFound
+	}
+
+	return rev, nil
+}
+
+func (s *server) handleUploadCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+
+		r.Body = http.MaxBytesReader(w, r.Body, s.config.UploadMaxBytes)
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer file.Close()
+
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		processed, err := processUpload(data)
+		if errors.Is(err, imaging.ErrTooLarge) {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		if err != nil {
+			s.error(w, r, http.StatusUnsupportedMediaType, err)
+			return
+		}
+
+		a, err := s.storeUpload(u.ID, processed)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err := s.store.Attachment().Create(a); err != nil {
+			s.deleteAttachments([]model.Attachment{*a})
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		attachments := []model.Attachment{*a}
+		setAttachmentURLs(attachments)
+		s.respond(w, r, http.StatusCreated, attachments[0])
+	}
+}
+
+func (s *server) handleUploadGet(thumb bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		a, err := s.store.Attachment().Find(id)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		// Attachments are as visible as their post; unattached uploads only to their owner.
+		u, _ := r.Context().Value(ctxKeyUser).(*model.User)
+		if a.PostID == nil {
+			if u == nil || u.ID != a.OwnerID {
+				s.error(w, r, http.StatusNotFound, store.ErrRecordNotFound)
+				return
+			}
+		} else {
+			post, err := s.store.Post().Find(*a.PostID)
+			if err != nil || !canView(r, post) {
+				s.error(w, r, http.StatusNotFound, store.ErrRecordNotFound)
+				return
+			}
+		}
+
+		key, contentType := a.Key, a.ContentType
+		if thumb {
+			if a.ThumbKey == "" {
+				s.error(w, r, http.StatusNotFound, blob.ErrNotFound)
+				return
+			}
+			key, contentType = a.ThumbKey, "image/jpeg"
+		}
+
+		rc, err := s.blobs.Get(key)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+		defer rc.Close()
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		io.Copy(w, rc)
+	}
+}
+
+func (s *server) loadAttachments(post *model.Post) error {
+	attachments, err := s.store.Attachment().FindByPost(post.ID)
+	if err != nil {
+		return err
+	}
+
+	setAttachmentURLs(attachments)
+	post.Attachments = attachments
+	return nil
+}
+
+func (s *server) handleStarGive() http.HandlerFunc {
+	return s.handleStar(true)
+}
+
+func (s *server) handleStarTake() http.HandlerFunc {
+	return s.handleStar(false)
+}
+
+// handleStar stars or unstars a visible post. It answers 202 when the star
+// was already in the requested state.
+func (s *server) handleStar(starred bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		post, ok := s.visiblePost(w, r)
+		if !ok {
+			return
+		}
+
+		starer := r.Context().Value(ctxKeyUser).(*model.User)
+		count, changed, err := s.setStar(starer, post, starred)
+		if err == errPostNotStarrable {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		star := &model.Star{
+			Starer: starer,
+			Post: &model.Post{
+				ID:         post.ID,
+				StarsCount: count,
+				IsStarred:  starred,
+			},
+		}
+
+		switch {
+		case !changed:
+			s.respond(w, r, http.StatusAccepted, star)
+		case starred:
+			s.respond(w, r, http.StatusCreated, star)
+		default:
+			s.respond(w, r, http.StatusOK, star)
+		}
+	}
+}
+
+// setStar stars or unstars the post for u and returns its star count and
+// whether anything changed, notifying and announcing only when it did.
+// Only published posts that aren't hidden can be starred.
+func (s *server) setStar(u *model.User, post *model.Post, starred bool) (int, bool, error) {
+	if starred && (!post.IsPublished() || post.HiddenAt != nil) {
+		return 0, false, errPostNotStarrable
+	}
+
+	isStarred, err := s.store.Post().IsStarredByUser(u.ID, post.ID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	changed := isStarred != starred
+	if changed && starred {
+		err = s.store.Star().Create(&model.Star{Starer: u, Post: post})
+	} else if changed {
+		err = s.store.Star().Delete(u.ID, post.ID)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	count, err := s.store.Post().GetStarsCount(post.ID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if changed {
+		if starred {
+			s.notifier.notify(post.Author.ID, u, model.NotificationStar, &post.ID)
+		}
+		s.announceStars(post.ID, count)
+	}
+
+	return count, changed, nil
+}
+
+func (s *server) handlePostsGet() http.HandlerFunc {
+	type response struct {
+		Items []model.Post `json:"items"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, offset, err := pageParams(r)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		posts, err := s.store.Post().FindAll(limit, offset)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err := s.markViewerState(r, posts); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := &response{
+			Items: posts,
+		}
+
+		s.respondCached(w, r, resp)
+	}
+}
+
+func (s *server) handlePostGet() http.HandlerFunc {
+	type response struct {
+		Item *model.Post `json:"items"`
+	}
+	// From v2 on the key matches handlePostGetByRef.
+	type responseV2 struct {
+		Item *model.Post `json:"item"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		post, err := s.store.Post().Find(id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if !canView(r, post) {
+			s.error(w, r, http.StatusNotFound, store.ErrRecordNotFound)
+			return
+		}
+
+		if err := s.loadAttachments(post); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if notModified(w, r, postETag(post)) {
+			return
+		}
+
+		if apiVersion(r) >= 2 {
+			s.respond(w, r, http.StatusOK, &responseV2{Item: post})
+			return
+		}
+
+		resp := &response{
+			Item: post,
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *server) handleGetUserByID() http.HandlerFunc {
+	type response struct {
+		User *model.User `json:"user"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		user, err := s.store.User().FindByID(id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		user.Stats, err = s.store.User().Stats(id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		setAvatarURLs(user)
+
+		resp := &response{
+			User: user,
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *server) handlePostsGetByUserID() http.HandlerFunc {
+	type response struct {
+		Items []model.Post `json:"items"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		posts, err := s.store.Post().FindByAuthor(id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err := s.markViewerState(r, posts); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := &response{
+			Items: posts,
+		}
+
+		s.respondCached(w, r, resp)
+	}
+}
+
+func (s *server) handleFeedGet() http.HandlerFunc {
+	type response struct {
+		Items []model.Post `json:"items"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, offset, err := pageParams(r)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		posts, err := s.store.Post().FindFeed(u.ID, limit, offset)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err := s.markViewerState(r, posts); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := &response{
+			Items: posts,
+		}
+
+		s.respondCached(w, r, resp)
+	}
+}
+
+func (s *server) handleFollow() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		follower := r.Context().Value(ctxKeyUser).(*model.User)
+		if follower.ID == id {
+			s.error(w, r, http.StatusUnprocessableEntity, errFollowSelf)
+			return
+		}
+
+		followee, err := s.store.User().FindByID(id)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		f := &model.Follow{
+			Follower: follower,
+			Followee: followee,
+		}
+		created, err := s.store.Follow().Create(f)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if created {
+			s.notifier.notifyOnce(followee.ID, follower, model.NotificationFollow, nil)
+		}
+		setAvatarURLs(followee)
+		s.respond(w, r, http.StatusCreated, f)
+	}
+}
+
+func (s *server) handleUnfollow() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		follower := r.Context().Value(ctxKeyUser).(*model.User)
+		if err := s.store.Follow().Delete(follower.ID, id); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
+
+func (s *server) handleFollowersGet() http.HandlerFunc {
+	return s.handleFollowList(func(id int) ([]model.User, error) {
+		return s.store.Follow().Followers(id)
+	})
+}
+
+func (s *server) handleFollowingGet() http.HandlerFunc {
+	return s.handleFollowList(func(id int) ([]model.User, error) {
+		return s.store.Follow().Following(id)
+	})
+}
+
+func (s *server) handleFollowList(find func(int) ([]model.User, error)) http.HandlerFunc {
+	type response struct {
+		Items []model.User `json:"items"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		users, err := find(id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		for i := range users {
+			setAvatarURLs(&users[i])
+		}
+
+		resp := &response{
+			Items: users,
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *server) handleCollectionsCreate() http.HandlerFunc {
+	type request struct {
+		Name     string `json:"name"`
+		IsPublic bool   `json:"is_public"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &request{}
+		if !s.decodeJSON(w, r, req) {
+			return
+		}
+
+		c := &model.Collection{
+			Owner:    r.Context().Value(ctxKeyUser).(*model.User),
+			Name:     req.Name,
+			IsPublic: req.IsPublic,
+		}
+		if c.IsPublic {
+			c.Slug = newShareSlug()
+		}
+		if err := s.store.Collection().Create(c); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusCreated, c)
+	}
+}
+
+func (s *server) handleCollectionsGet() http.HandlerFunc {
+	type response struct {
+		Items []model.Collection `json:"items"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		collections, err := s.store.Collection().FindByOwner(u.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := &response{
+			Items: collections,
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *server) handleCollectionGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := s.ownCollection(w, r)
+		if !ok {
+			return
+		}
+
+		s.respondCollection(w, r, c)
+	}
+}
+
+func (s *server) handleSharedCollectionGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := s.store.Collection().FindBySlug(mux.Vars(r)["slug"])
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		s.respondCollection(w, r, c)
+	}
+}
+
+func (s *server) respondCollection(w http.ResponseWriter, r *http.Request, c *model.Collection) {
+	posts, err := s.store.Post().FindByCollection(c.ID)
+	if err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := s.markViewerState(r, posts); err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Posts = posts
+	s.respond(w, r, http.StatusOK, c)
+}
+
+func (s *server) handleCollectionUpdate() http.HandlerFunc {
+	type request struct {
+		Name     string `json:"name"`
+		IsPublic bool   `json:"is_public"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := s.ownCollection(w, r)
+		if !ok {
+			return
+		}
+
+		req := &request{}
+		if !s.decodeJSON(w, r, req) {
+			return
+		}
+
+		c.Name = req.Name
+		c.IsPublic = req.IsPublic
+		if c.IsPublic && c.Slug == "" {
+			c.Slug = newShareSlug()
+		}
+		if err := s.store.Collection().Update(c); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, c)
+	}
+}
+
+func (s *server) handleCollectionDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := s.ownCollection(w, r)
+		if !ok {
+			return
+		}
+
+		if err := s.store.Collection().Delete(c.ID); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
+
+func (s *server) handleCollectionAddPost() http.HandlerFunc {
+	type request struct {
+		PostID int `json:"post_id"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := s.ownCollection(w, r)
+		if !ok {
+			return
+		}
+
+		req := &request{}
+		if !s.decodeJSON(w, r, req) {
+			return
+		}
+
+		post, err := s.store.Post().Find(req.PostID)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		if !canView(r, post) {
+			s.error(w, r, http.StatusNotFound, store.ErrRecordNotFound)
+			return
+		}
+
+		if err := s.store.Collection().AddPost(c.ID, req.PostID); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respondCollection(w, r, c)
+	}
+}
+
+func (s *server) handleCollectionRemovePost() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := s.ownCollection(w, r)
+		if !ok {
+			return
+		}
+
+		postID, err := strconv.Atoi(mux.Vars(r)["post_id"])
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		if err := s.store.Collection().RemovePost(c.ID, postID); err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
+
+func (s *server) handleCollectionReorder() http.HandlerFunc {
+	type request struct {
+		PostIDs []int `json:"post_ids"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := s.ownCollection(w, r)
+		if !ok {
+			return
+		}
+
+		req := &request{}
+		if !s.decodeJSON(w, r, req) {
+			return
+		}
+
+		current, err := s.store.Collection().PostIDs(c.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		// The order covers the posts the collection shows; saved posts that
+		// are trashed, hidden or unpublished keep their slots.
+		posts, err := s.store.Post().FindByCollection(c.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		shown := make([]int, len(posts))
+		for i, post := range posts {
+			shown[i] = post.ID
+		}
+
+		if !samePostIDs(shown, req.PostIDs) {
+			s.error(w, r, http.StatusUnprocessableEntity, errOrderMismatch)
+			return
+		}
+
+		if err := s.store.Collection().Reorder(c.ID, mergeOrder(current, shown, req.PostIDs)); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respondCollection(w, r, c)
+	}
+}
+
+func (s *server) handleAdminUsersGet() http.HandlerFunc {
+	type response struct {
+		Items []model.User `json:"items"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, offset, err := pageParams(r)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		users, err := s.store.User().Search(r.URL.Query().Get("q"), limit, offset)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := &response{
+			Items: users,
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *server) handleAdminBan(banned bool) http.HandlerFunc {
+	action := "user.unban"
+	if banned {
+		action = "user.ban"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		actor := r.Context().Value(ctxKeyUser).(*model.User)
+		target, err := s.store.User().Find(id)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		if !canModerate(actor, target) {
+			s.error(w, r, http.StatusForbidden, errNoPermission)
+			return
+		}
+
+		if err := s.store.User().SetBanned(id, banned); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.audit(actor, action, "user", id, nil)
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
+
+func (s *server) handleAdminRoleSet() http.HandlerFunc {
+	type request struct {
+		Role string `json:"role"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		req := &request{}
+		if !s.decodeJSON(w, r, req) {
+			return
+		}
+
+		actor := r.Context().Value(ctxKeyUser).(*model.User)
+		if id == actor.ID {
+			s.error(w, r, http.StatusForbidden, errNoPermission)
+			return
+		}
+
+		target, err := s.store.User().Find(id)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		previous := target.Role
+		target.Role = req.Role
+		if err := s.store.User().SetRole(target); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		s.audit(actor, "user.role", "user", id, map[string]interface{}{
+			"from": previous,
+			"to":   target.Role,
+		})
+		target.Sanitize()
+		s.respond(w, r, http.StatusOK, target)
+	}
+}
+
+// moderatedPost loads the post from the {id} route variable for the signed-in
+// moderator to act on, refusing posts by authors canModerate protects from them.
+func (s *server) moderatedPost(w http.ResponseWriter, r *http.Request) (*model.Post, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		s.error(w, r, http.StatusUnprocessableEntity, err)
+		return nil, false
+	}
+
+	post, err := s.store.Post().Find(id)
+	if err != nil {
+		s.error(w, r, http.StatusNotFound, err)
+		return nil, false
+	}
+
+	author, err := s.store.User().Find(post.Author.ID)
+	if err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	if !canModerate(r.Context().Value(ctxKeyUser).(*model.User), author) {
+		s.error(w, r, http.StatusForbidden, errNoPermission)
+		return nil, false
+	}
+
+	return post, true
+}
+
+func (s *server) handleAdminPostDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		post, ok := s.moderatedPost(w, r)
+		if !ok {
+			return
+		}
+
+		actor := r.Context().Value(ctxKeyUser).(*model.User)
+		if err := s.store.Post().Delete(post.ID, actor.ID, 0); err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		s.audit(actor, "post.delete", "post", post.ID, nil)
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
+
+func (s *server) handleAdminPostHide(hidden bool) http.HandlerFunc {
+	action := "post.unhide"
+	if hidden {
+		action = "post.hide"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		post, ok := s.moderatedPost(w, r)
+		if !ok {
+			return
+		}
+
+		if !s.checkIfMatch(w, r, post) {
+			return
+		}
+
+		if err := s.store.Post().SetHidden(post.ID, hidden, ifMatchVersion(r, post)); err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		s.audit(r.Context().Value(ctxKeyUser).(*model.User), action, "post", post.ID, nil)
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
+
+func (s *server) handleAdminReportsGet() http.HandlerFunc {
+	type response struct {
+		Items []model.Report `json:"items"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, offset, err := pageParams(r)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		reports, err := s.store.Report().FindOpen(limit, offset)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := &response{
+			Items: reports,
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+// handleAdminReportResolve applies the moderator's decision to the reported
+// post and closes every open report on it.
+func (s *server) handleAdminReportResolve() http.HandlerFunc {
+	type request struct {
+		Resolution string `json:"resolution"`
+	}
+	type response struct {
+		Items []model.Report `json:"items"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		req := &request{}
+		if !s.decodeJSON(w, r, req) {
+			return
+		}
+
+		if err := model.ValidResolution(req.Resolution); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		report, err := s.store.Report().Find(id)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		if report.Status != model.ReportStatusOpen {
+			s.error(w, r, http.StatusConflict, errReportResolved)
+			return
+		}
+
+		actor := r.Context().Value(ctxKeyUser).(*model.User)
+		// Hiding, deleting and banning all act against the author.
+		var author *model.User
+		if req.Resolution != model.ReportResolutionDismiss {
+			author, err = s.reportedAuthor(actor, report.PostID)
+			if err == errNoPermission {
+				s.error(w, r, http.StatusForbidden, err)
+				return
+			}
+			if err == store.ErrRecordNotFound {
+				s.error(w, r, http.StatusNotFound, err)
+				return
+			}
+			if err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+		}
+
+		resolved, err := s.store.Report().Resolve(report.PostID, actor.ID, req.Resolution)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if len(resolved) == 0 {
+			s.error(w, r, http.StatusConflict, errReportResolved)
+			return
+		}
+
+		if req.Resolution == model.ReportResolutionBanAuthor {
+			s.audit(actor, "user.ban", "user", author.ID, map[string]interface{}{"post_id": report.PostID})
+		}
+
+		for _, rep := range resolved {
+			postID := rep.PostID
+			s.notifier.notify(rep.ReporterID, nil, model.NotificationReportResolved, &postID)
+		}
+
+		s.audit(actor, "report.resolve", "post", report.PostID, map[string]interface{}{
+			"report_id":  report.ID,
+			"resolution": req.Resolution,
+			"reports":    len(resolved),
+		})
+
+		resp := &response{
+			Items: resolved,
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+// canModerate reports whether actor may act against target: only admins may act against staff, and nobody against themselves.
+func canModerate(actor *model.User, target *model.User) bool {
+	if target.ID == actor.ID {
+		return false
+	}
+
+	return !target.HasRole(model.RoleModerator) || actor.HasRole(model.RoleAdmin)
+}
+
+// reportedAuthor returns the author of a reported post if actor may act against them.
+func (s *server) reportedAuthor(actor *model.User, postID int) (*model.User, error) {
+	post, err := s.store.Post().Find(postID)
+	if err != nil {
+		return nil, err
+	}
+
+	author, err := s.store.User().Find(post.Author.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canModerate(actor, author) {
+		return nil, errNoPermission
+	}
+
+	return author, nil
+}
+
+func (s *server) handleAdminStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := s.store.Audit().SystemStats()
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, stats)
+	}
+}
+
+func (s *server) handleAdminAuditGet() http.HandlerFunc {
+	type response struct {
+		Items []model.AuditEntry `json:"items"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, offset, err := pageParams(r)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		entries, err := s.store.Audit().FindAll(limit, offset)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := &response{
+			Items: entries,
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+// audit records an admin action. Failing to record it is logged rather than failing the action, which already happened.
+func (s *server) audit(actor *model.User, action string, targetType string, targetID int, details map[string]interface{}) {
+	e := &model.AuditEntry{
+		ActorID:    actor.ID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+	}
+	if err := s.store.Audit().Create(e); err != nil {
+		s.logger.Errorf("recording audit entry %s %s/%d by %d: %v", action, targetType, targetID, actor.ID, err)
+	}
+}
+
+func (s *server) handleWhoami() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		setAvatarURLs(u)
+		s.respond(w, r, http.StatusOK, u)
+	}
+}
+
+func (s *server) handleProfileUpdate() http.HandlerFunc {
+	type request struct {
+		DisplayName string `json:"display_name"`
+		Bio         string `json:"bio"`
+		Website     string `json:"website"`
+		Location    string `json:"location"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &request{}
+		if !s.decodeJSON(w, r, req) {
+			return
+		}
+
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		u.DisplayName = req.DisplayName
+		u.Bio = req.Bio
+		u.Website = req.Website
+		u.Location = req.Location
+		if err := s.store.User().UpdateProfile(u); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		u.Sanitize()
+		setAvatarURLs(u)
+		s.respond(w, r, http.StatusOK, u)
+	}
+}
+
+func (s *server) handleAvatarUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+
+		r.Body = http.MaxBytesReader(w, r.Body, s.config.AvatarMaxBytes)
+		file, _, err := r.FormFile("avatar")
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer file.Close()
+
+		img, _, err := imaging.Decode(file)
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		base, err := storeAvatar(s.blobs, u.ID, img)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err := s.store.User().UpdateAvatar(u.ID, base); err != nil {
+			deleteAvatar(s.blobs, base)
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if u.Avatar != "" {
+			if err := deleteAvatar(s.blobs, u.Avatar); err != nil {
+				s.logger.Warnf("removing old avatar %s: %v", u.Avatar, err)
+			}
+		}
+
+		u.Avatar = base
+		u.Sanitize()
+		setAvatarURLs(u)
+		s.respond(w, r, http.StatusOK, u)
+	}
+}
+
+func (s *server) handleAvatarGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		size, err := strconv.Atoi(vars["size"])
+		if err != nil || !isAvatarSize(size) {
+			s.error(w, r, http.StatusNotFound, errInvalidAvatarSize)
+			return
+		}
+
+		u, err := s.store.User().FindByID(id)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		if u.Avatar == "" {
+			s.error(w, r, http.StatusNotFound, blob.ErrNotFound)
+			return
+		}
+
+		rc, err := s.blobs.Get(avatarKey(u.Avatar, size))
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+		defer rc.Close()
+
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		io.Copy(w, rc)
+	}
+}
+
+func (s *server) handleExportCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+
+		sessions, err := s.exportSessions(r)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		size, err := s.store.User().ContentCount(u.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if size > s.config.ExportAsyncThreshold {
+			job := s.exporter.start(u.ID, func() (*userExport, error) {
+				return s.collectExport(u.ID, sessions)
+			})
+			w.Header().Set("Location", apiBasePath+"/private/me/export/"+job.ID)
+			s.respond(w, r, http.StatusAccepted, job)
+			return
+		}
+
+		data, err := s.collectExport(u.ID, sessions)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"export-%d.zip\"", u.ID))
+		w.WriteHeader(http.StatusOK)
+		if err := writeExport(w, data); err != nil {
+			s.logger.Errorf("writing export for user %d: %v", u.ID, err)
+		}
+	}
+}
+
+func (s *server) handleExportGet() http.HandlerFunc {
+	type response struct {
+		exportJob
+		URL string `json:"url,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+
+		job, err := s.exporter.find(u.ID, mux.Vars(r)["id"])
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		resp := &response{exportJob: job}
+		if job.Status == exportStatusDone {
+			resp.URL = apiBasePath + "/private/me/export/" + job.ID + "/download"
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *server) handleExportDownload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+
+		job, err := s.exporter.find(u.ID, mux.Vars(r)["id"])
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		if job.Status != exportStatusDone {
+			s.error(w, r, http.StatusConflict, errExportNotReady)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"export-%d.zip\"", u.ID))
+		http.ServeFile(w, r, job.path)
+	}
+}
+
+func (s *server) collectExport(userID int, sessions []map[string]interface{}) (*userExport, error) {
+	profile, err := s.store.User().Find(userID)
+	if err != nil {
+		return nil, err
+	}
+	profile.Sanitize()
+
+	posts, err := s.store.Post().FindOwn(userID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	stars, err := s.store.Star().FindByStarer(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userExport{
+		Profile:  profile,
+		Posts:    posts,
+		Stars:    stars,
+		Sessions: sessions,
+	}, nil
+}
+
+// exportSessions describes the user's sessions. They live in signed cookies,
+// so the only one we can see is the current one.
+func (s *server) exportSessions(r *http.Request) ([]map[string]interface{}, error) {
+	session, err := s.sessionStore.Get(r, sessionName)
+	if err != nil {
+		return nil, err
+	}
+
+	return []map[string]interface{}{
+		{
+			"name":    sessionName,
+			"current": true,
+			"max_age": session.Options.MaxAge,
+			"user_id": session.Values["user_id"],
+		},
+	}, nil
+}
+
+// markViewerState sets IsStarred and IsBookmarked on posts for the signed-in viewer, if there is one.
+func (s *server) markViewerState(r *http.Request, posts []model.Post) error {
+	u, ok := r.Context().Value(ctxKeyUser).(*model.User)
+	if !ok {
+		return nil
+	}
+
+	for i := range posts {
+		starred, err := s.store.Post().IsStarredByUser(u.ID, posts[i].ID)
+		if err != nil {
+			return err
+		}
+		posts[i].IsStarred = starred
+
+		bookmarked, err := s.store.Collection().IsBookmarked(u.ID, posts[i].ID)
+		if err != nil {
+			return err
+		}
+		posts[i].IsBookmarked = bookmarked
+	}
+
+	return nil
+}
+
+// ownCollection loads the collection from the {id} route variable and checks that the viewer owns it.
+func (s *server) ownCollection(w http.ResponseWriter, r *http.Request) (*model.Collection, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		s.error(w, r, http.StatusUnprocessableEntity, err)
+		return nil, false
+	}
+
+	c, err := s.store.Collection().Find(id)
+	if err != nil {
+		s.error(w, r, http.StatusNotFound, err)
+		return nil, false
+	}
+
+	u := r.Context().Value(ctxKeyUser).(*model.User)
+	if c.Owner.ID != u.ID {
+		s.error(w, r, http.StatusUnauthorized, errNoPermission)
+		return nil, false
+	}
+
+	return c, true
+}
+
+func (s *server) error(w http.ResponseWriter, r *http.Request, code int, err error) {
+	p := newProblem(r, code, err)
+	if p.Status >= http.StatusInternalServerError {
+		s.logger.WithField("request_id", p.RequestID).Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	s.respond(w, r, p.Status, p)
 }
 
 func (s *server) respond(w http.ResponseWriter, r *http.Request, code int, data interface{}) {
@@ -0,0 +1,87 @@
+package apiserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+const (
+	streamHeartbeat = 15 * time.Second
+	streamRetry     = 3 * time.Second
+)
+
+var errStreamingUnsupported = errors.New("streaming unsupported")
+
+// handleStream serves hub events as Server-Sent Events. Anonymous viewers
+// get broadcast events only; signed-in users also get their notifications.
+func (s *server) handleStream() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			s.error(w, r, http.StatusInternalServerError, errStreamingUnsupported)
+			return
+		}
+
+		userID := 0
+		if u, ok := r.Context().Value(ctxKeyUser).(*model.User); ok {
+			userID = u.ID
+		}
+
+		lastID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+		sub, missed := s.hub.subscribe(userID, lastID)
+		defer s.hub.unsubscribe(sub)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+		for _, e := range missed {
+			if e.Type == eventPostTyping {
+				continue
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-sub.events:
+				if !ok {
+					return
+				}
+				// Typing presence is only meaningful to WebSocket clients.
+				if e.Type == eventPostTyping {
+					continue
+				}
+				if err := writeEvent(w, e); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w io.Writer, e *event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+	return err
+}
@@ -0,0 +1,57 @@
+package apiserver
+
+import "time"
+
+// tokenBucket allows rate events per second with bursts of up to burst.
+// It is not safe for concurrent use.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst float64) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+	}
+}
+
+func (b *tokenBucket) allow(now time.Time) bool {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// remaining returns the whole tokens left after the last event.
+func (b *tokenBucket) remaining() int {
+	return int(b.tokens)
+}
+
+// wait returns how long after the last event the bucket holds n tokens again.
+func (b *tokenBucket) wait(n float64) time.Duration {
+	if b.tokens >= n || b.rate <= 0 {
+		return 0
+	}
+
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+// fullAt returns when the bucket refills completely, after which it's
+// indistinguishable from a new one.
+func (b *tokenBucket) fullAt() time.Time {
+	return b.last.Add(b.wait(b.burst))
+}
@@ -0,0 +1,84 @@
+package apiserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket_Allow(t *testing.T) {
+	start := time.Date(2021, 7, 19, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	testCases := []struct {
+		name  string
+		rate  float64
+		burst float64
+		times []time.Time
+		want  []bool
+	}{
+		{
+			name:  "burst then empty",
+			rate:  1,
+			burst: 3,
+			times: []time.Time{at(0), at(0), at(0), at(0)},
+			want:  []bool{true, true, true, false},
+		},
+		{
+			name:  "refills at the rate",
+			rate:  2,
+			burst: 1,
+			times: []time.Time{at(0), at(0), at(250 * time.Millisecond), at(500 * time.Millisecond)},
+			want:  []bool{true, false, false, true},
+		},
+		{
+			name:  "refill caps at burst",
+			rate:  1,
+			burst: 2,
+			times: []time.Time{at(0), at(0), at(time.Hour), at(time.Hour), at(time.Hour)},
+			want:  []bool{true, true, true, true, false},
+		},
+		{
+			name:  "denied requests don't spend tokens",
+			rate:  1,
+			burst: 1,
+			times: []time.Time{at(0), at(500 * time.Millisecond), at(time.Second)},
+			want:  []bool{true, false, true},
+		},
+		{
+			name:  "slow rate",
+			rate:  1.0 / 60,
+			burst: 1,
+			times: []time.Time{at(0), at(59 * time.Second), at(60 * time.Second)},
+			want:  []bool{true, false, true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newTokenBucket(tc.rate, tc.burst)
+			got := make([]bool, len(tc.times))
+			for i, now := range tc.times {
+				got[i] = b.allow(now)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestTokenBucket_Wait(t *testing.T) {
+	now := time.Date(2021, 7, 19, 12, 0, 0, 0, time.UTC)
+	b := newTokenBucket(2, 4)
+	for i := 0; i < 4; i++ {
+		assert.True(t, b.allow(now))
+	}
+
+	assert.Equal(t, 0, b.remaining())
+	assert.Equal(t, 500*time.Millisecond, b.wait(1))
+	assert.Equal(t, 2*time.Second, b.wait(4))
+	assert.Equal(t, now.Add(2*time.Second), b.fullAt())
+
+	assert.False(t, b.allow(now.Add(400*time.Millisecond)))
+	assert.True(t, b.allow(now.Add(500*time.Millisecond)))
+}
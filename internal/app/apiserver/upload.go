@@ -0,0 +1,164 @@
+package apiserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zlyaptica/http-rest-api/internal/app/imaging"
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+const (
+	thumbWidth  = 320
+	thumbHeight = 320
+)
+
+var (
+	errUnsupportedUpload = errors.New("unsupported file type")
+)
+
+// uploadTypes maps every accepted sniffed content type to the extension it's stored with.
+var uploadTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"application/pdf": ".pdf",
+}
+
+type processedUpload struct {
+	contentType string
+	ext         string
+	body        []byte
+	thumb       []byte
+	width       int
+	height      int
+}
+
+// processUpload sniffs the content type, strips metadata from JPEG and PNG
+// images by re-encoding them, and renders a thumbnail for every image.
+func processUpload(data []byte) (*processedUpload, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := uploadTypes[contentType]
+	if !ok {
+		return nil, errUnsupportedUpload
+	}
+
+	p := &processedUpload{
+		contentType: contentType,
+		ext:         ext,
+		body:        data,
+	}
+	if contentType == "application/pdf" {
+		return p, nil
+	}
+
+	img, _, err := imaging.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	p.width = img.Bounds().Dx()
+	p.height = img.Bounds().Dy()
+
+	// GIFs carry no EXIF and re-encoding would drop their animation, so they're kept as uploaded.
+	switch contentType {
+	case "image/jpeg":
+		if p.body, err = encode(imaging.EncodeJPEG, img); err != nil {
+			return nil, err
+		}
+	case "image/png":
+		if p.body, err = encode(imaging.EncodePNG, img); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.thumb, err = encode(imaging.EncodeJPEG, imaging.Fit(img, thumbWidth, thumbHeight)); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func encode(fn func(w io.Writer, img image.Image) error, img image.Image) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := fn(buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// storeUpload writes the processed file and its thumbnail to blob storage.
+func (s *server) storeUpload(ownerID int, p *processedUpload) (*model.Attachment, error) {
+	base := fmt.Sprintf("attachments/%d/%s", ownerID, uuid.New().String())
+	a := &model.Attachment{
+		OwnerID:     ownerID,
+		Key:         base + p.ext,
+		ContentType: p.contentType,
+		Size:        int64(len(p.body)),
+		Width:       p.width,
+		Height:      p.height,
+	}
+
+	if err := s.blobs.Put(a.Key, bytes.NewReader(p.body), a.ContentType); err != nil {
+		return nil, err
+	}
+
+	if p.thumb != nil {
+		a.ThumbKey = base + "-thumb.jpg"
+		if err := s.blobs.Put(a.ThumbKey, bytes.NewReader(p.thumb), "image/jpeg"); err != nil {
+			s.blobs.Delete(a.Key)
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// deleteAttachments removes the files and then the rows of attachments.
+func (s *server) deleteAttachments(attachments []model.Attachment) {
+	for _, a := range attachments {
+		if err := s.blobs.Delete(a.Key); err != nil {
+			s.logger.Errorf("deleting attachment %d: %v", a.ID, err)
+			continue
+		}
+
+		if a.ThumbKey != "" {
+			if err := s.blobs.Delete(a.ThumbKey); err != nil {
+				s.logger.Errorf("deleting thumbnail of attachment %d: %v", a.ID, err)
+				continue
+			}
+		}
+
+		if err := s.store.Attachment().Delete(a.ID); err != nil {
+			s.logger.Errorf("deleting attachment %d: %v", a.ID, err)
+		}
+	}
+}
+
+// collectOrphanedAttachments removes uploads that were never attached to a post within the grace period.
+func (s *server) collectOrphanedAttachments() {
+	grace := time.Duration(s.config.UploadOrphanGrace) * time.Hour
+	orphans, err := s.store.Attachment().FindOrphans(time.Now().Add(-grace))
+	if err != nil {
+		s.logger.Errorf("finding orphaned attachments: %v", err)
+		return
+	}
+
+	s.deleteAttachments(orphans)
+}
+
+func setAttachmentURLs(attachments []model.Attachment) {
+	for i := range attachments {
+		a := &attachments[i]
+		a.URL = fmt.Sprintf("%s/uploads/%d", apiBasePath, a.ID)
+		if a.ThumbKey != "" {
+			a.ThumbURL = fmt.Sprintf("%s/uploads/%d/thumb", apiBasePath, a.ID)
+		}
+	}
+}
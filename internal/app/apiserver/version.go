@@ -0,0 +1,59 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// apiBasePath prefixes the URLs the API hands out.
+const apiBasePath = "/v1"
+
+// apiVersions are mounted under /v<N>, each with the full route table.
+// Handlers whose response shape changes between versions branch on
+// apiVersion(r), so a new version only needs adding here.
+var apiVersions = []int{1}
+
+func setAPIVersion(version int) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyAPIVersion, version)))
+		})
+	}
+}
+
+// apiVersion returns the version the request was routed to.
+func apiVersion(r *http.Request) int {
+	if v, ok := r.Context().Value(ctxKeyAPIVersion).(int); ok {
+		return v
+	}
+
+	return 1
+}
+
+// deprecateUnversioned marks responses from the unversioned aliases as
+// deprecated and points clients to the /v1 equivalent.
+func (s *server) deprecateUnversioned(next http.Handler) http.Handler {
+	sunset := ""
+	if s.config.LegacySunset != "" {
+		t, err := time.Parse("2006-01-02", s.config.LegacySunset)
+		if err != nil {
+			s.logger.Warnf("ignoring invalid legacy_sunset %q: %v", s.config.LegacySunset, err)
+		} else {
+			sunset = t.UTC().Format(http.TimeFormat)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		if sunset != "" {
+			w.Header().Set("Sunset", sunset)
+		}
+		w.Header().Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", apiBasePath, r.URL.Path))
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,272 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+// WebSocket message types ...
+const (
+	wsSubscribe   = "subscribe"
+	wsUnsubscribe = "unsubscribe"
+	wsStar        = "star"
+	wsUnstar      = "unstar"
+	wsTyping      = "typing"
+	wsSubscribed  = "subscribed"
+	wsError       = "error"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = wsPongWait * 9 / 10
+	wsMaxMessageSize = 4096
+	wsOutBuffer      = 16
+	wsMessageRate    = 5
+	wsMessageBurst   = 10
+)
+
+var (
+	errUnknownMessageType = errors.New("unknown message type")
+	errNotSubscribed      = errors.New("not subscribed to post")
+	errRateLimited        = errors.New("rate limit exceeded")
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     checkOrigin,
+}
+
+// checkOrigin accepts same-host requests and the web client's origin.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == allowedOrigin {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+type wsRequest struct {
+	Type   string `json:"type"`
+	PostID int    `json:"post_id"`
+}
+
+type wsMessage struct {
+	Type   string      `json:"type"`
+	ID     uint64      `json:"id,omitempty"`
+	PostID int         `json:"post_id,omitempty"`
+	Data   interface{} `json:"data,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+type wsStarResult struct {
+	PostID     int  `json:"post_id"`
+	StarsCount int  `json:"stars_count"`
+	IsStarred  bool `json:"is_starred"`
+}
+
+// wsConn is one WebSocket client. The read loop handles requests and queues
+// replies on out; the write loop is the only goroutine writing to conn.
+type wsConn struct {
+	s       *server
+	conn    *websocket.Conn
+	user    *model.User
+	sub     *subscriber
+	out     chan *wsMessage
+	done    chan struct{}
+	limiter *tokenBucket
+}
+
+// handleWebSocket upgrades session-authenticated requests to a WebSocket
+// carrying notifications, new posts and events for subscribed post channels.
+func (s *server) handleWebSocket() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		c := &wsConn{
+			s:       s,
+			conn:    conn,
+			user:    u,
+			sub:     s.hub.subscribeChannels(u.ID),
+			out:     make(chan *wsMessage, wsOutBuffer),
+			done:    make(chan struct{}),
+			limiter: newTokenBucket(wsMessageRate, wsMessageBurst),
+		}
+
+		go c.writeLoop()
+		c.readLoop()
+		s.hub.unsubscribe(c.sub)
+	}
+}
+
+func (c *wsConn) readLoop() {
+	defer close(c.done)
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		req := &wsRequest{}
+		if err := json.Unmarshal(data, req); err != nil {
+			c.fail(req, err)
+			continue
+		}
+
+		if !c.limiter.allow(time.Now()) {
+			c.fail(req, errRateLimited)
+			continue
+		}
+
+		c.handle(req)
+	}
+}
+
+func (c *wsConn) handle(req *wsRequest) {
+	switch req.Type {
+	case wsSubscribe:
+		if _, err := c.visiblePost(req.PostID); err != nil {
+			c.fail(req, err)
+			return
+		}
+
+		c.s.hub.join(c.sub, req.PostID)
+		c.send(&wsMessage{Type: wsSubscribed, PostID: req.PostID})
+	case wsUnsubscribe:
+		c.s.hub.leave(c.sub, req.PostID)
+	case wsStar, wsUnstar:
+		post, err := c.visiblePost(req.PostID)
+		if err != nil {
+			c.fail(req, err)
+			return
+		}
+
+		starred := req.Type == wsStar
+		count, _, err := c.s.setStar(c.user, post, starred)
+		if err != nil {
+			c.fail(req, err)
+			return
+		}
+
+		c.send(&wsMessage{
+			Type:   req.Type,
+			PostID: post.ID,
+			Data: &wsStarResult{
+				PostID:     post.ID,
+				StarsCount: count,
+				IsStarred:  starred,
+			},
+		})
+	case wsTyping:
+		if !c.s.hub.joined(c.sub, req.PostID) {
+			c.fail(req, errNotSubscribed)
+			return
+		}
+
+		c.s.hub.publishLocal(eventPostTyping, req.PostID, &typingEvent{
+			PostID: req.PostID,
+			User:   &model.User{ID: c.user.ID, Username: c.user.Username},
+		})
+	default:
+		c.fail(req, errUnknownMessageType)
+	}
+}
+
+func (c *wsConn) visiblePost(id int) (*model.Post, error) {
+	post, err := c.s.store.Post().Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canViewAs(c.user, post) {
+		return nil, errNoPermission
+	}
+
+	return post, nil
+}
+
+func (c *wsConn) fail(req *wsRequest, err error) {
+	c.send(&wsMessage{
+		Type:   wsError,
+		PostID: req.PostID,
+		Error:  err.Error(),
+	})
+}
+
+// send queues a reply. A client that stops reading fills the queue and is disconnected.
+func (c *wsConn) send(m *wsMessage) {
+	select {
+	case c.out <- m:
+	default:
+		c.conn.Close()
+	}
+}
+
+func (c *wsConn) writeLoop() {
+	ping := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ping.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case m := <-c.out:
+			if err := c.write(m); err != nil {
+				return
+			}
+		case e, ok := <-c.sub.events:
+			if !ok {
+				// The hub dropped us for falling behind.
+				c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+				c.conn.WriteMessage(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"),
+				)
+				return
+			}
+
+			m := &wsMessage{
+				Type:   e.Type,
+				ID:     e.ID,
+				PostID: e.PostID,
+				Data:   e.Data,
+			}
+			if err := c.write(m); err != nil {
+				return
+			}
+		case <-ping.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *wsConn) write(m *wsMessage) error {
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteJSON(m)
+}
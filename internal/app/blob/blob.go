@@ -0,0 +1,18 @@
+package blob
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	// ErrNotFound ...
+	ErrNotFound = errors.New("blob not found")
+)
+
+// Storage ...
+type Storage interface {
+	Put(key string, r io.Reader, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
@@ -0,0 +1,144 @@
+package s3blob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zlyaptica/http-rest-api/internal/app/blob"
+)
+
+const (
+	testRegion    = "eu-central-1"
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+// verifySignature checks a request's SigV4 Authorization header the way the
+// service would, rebuilding the canonical request from what arrived.
+func verifySignature(r *http.Request) error {
+	amzDate := r.Header.Get("X-Amz-Date")
+	date, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil {
+		return fmt.Errorf("bad X-Amz-Date %q", amzDate)
+	}
+
+	payload := r.Header.Get("X-Amz-Content-Sha256")
+	canonical := r.Method + "\n" +
+		r.URL.EscapedPath() + "\n" +
+		r.URL.RawQuery + "\n" +
+		"host:" + r.Host + "\n" +
+		"x-amz-content-sha256:" + payload + "\n" +
+		"x-amz-date:" + amzDate + "\n" +
+		"\n" +
+		"host;x-amz-content-sha256;x-amz-date\n" +
+		payload
+
+	scope := date.Format("20060102") + "/" + testRegion + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range []string{date.Format("20060102"), testRegion, "s3", "aws4_request", stringToSign} {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(part))
+		key = h.Sum(nil)
+	}
+
+	want := "AWS4-HMAC-SHA256 Credential=" + testAccessKey + "/" + scope +
+		", SignedHeaders=host;x-amz-content-sha256;x-amz-date" +
+		", Signature=" + hex.EncodeToString(key)
+	if got := r.Header.Get("Authorization"); got != want {
+		return fmt.Errorf("Authorization = %q, want %q", got, want)
+	}
+
+	return nil
+}
+
+func newTestStorage(t *testing.T, handler http.HandlerFunc) *Storage {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := verifySignature(r); err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	return New(Config{
+		Endpoint:  srv.URL + "/",
+		Region:    testRegion,
+		Bucket:    "uploads",
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+	})
+}
+
+func TestStorage_Put(t *testing.T) {
+	var method, path, contentType, body string
+	s := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		method, path, contentType, body = r.Method, r.URL.Path, r.Header.Get("Content-Type"), string(b)
+	})
+
+	err := s.Put("/2021/07/photo one.png", strings.NewReader("image data"), "image/png")
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/uploads/2021/07/photo one.png", path)
+	assert.Equal(t, "image/png", contentType)
+	assert.Equal(t, "image data", body)
+}
+
+func TestStorage_Get(t *testing.T) {
+	s := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/uploads/a.txt" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte("hello"))
+	})
+
+	rc, err := s.Get("a.txt")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rc.Close()
+
+	b, err := ioutil.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(b))
+}
+
+func TestStorage_Errors(t *testing.T) {
+	s := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/uploads/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("<Error>InternalError</Error>"))
+		}
+	})
+
+	_, err := s.Get("missing")
+	assert.Equal(t, blob.ErrNotFound, err)
+
+	assert.NoError(t, s.Delete("missing"), "deleting a missing blob succeeds")
+
+	err = s.Put("broken", strings.NewReader("x"), "text/plain")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "500")
+		assert.Contains(t, err.Error(), "InternalError")
+	}
+}
@@ -0,0 +1,138 @@
+package diff
+
+import "strings"
+
+// Line operations ...
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// Line ...
+type Line struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines returns a line-level diff turning a into b, based on their longest common subsequence.
+// The subsequence is found with Hirschberg's algorithm, so memory stays linear in the input.
+func Lines(a, b string) []Line {
+	return appendDiff([]Line{}, split(a), split(b))
+}
+
+func appendDiff(lines []Line, x, y []string) []Line {
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	lines = appendOp(lines, OpEqual, x[:prefix])
+	x, y = x[prefix:], y[prefix:]
+
+	suffix := 0
+	for suffix < len(x) && suffix < len(y) && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	common := x[len(x)-suffix:]
+	x, y = x[:len(x)-suffix], y[:len(y)-suffix]
+
+	switch {
+	case len(x) == 0:
+		lines = appendOp(lines, OpInsert, y)
+	case len(y) == 0:
+		lines = appendOp(lines, OpDelete, x)
+	case len(x) == 1:
+		k := index(y, x[0])
+		if k < 0 {
+			lines = appendOp(lines, OpDelete, x)
+			lines = appendOp(lines, OpInsert, y)
+			break
+		}
+		lines = appendOp(lines, OpInsert, y[:k])
+		lines = appendOp(lines, OpEqual, x)
+		lines = appendOp(lines, OpInsert, y[k+1:])
+	default:
+		// Split x in half and y where the halves' subsequences meet best.
+		mid := len(x) / 2
+		head := lcsPrefix(x[:mid], y)
+		tail := lcsSuffix(x[mid:], y)
+		split := 0
+		for k := range head {
+			if head[k]+tail[k] > head[split]+tail[split] {
+				split = k
+			}
+		}
+		lines = appendDiff(lines, x[:mid], y[:split])
+		lines = appendDiff(lines, x[mid:], y[split:])
+	}
+
+	return appendOp(lines, OpEqual, common)
+}
+
+// lcsPrefix returns, for every j, the LCS length of x and y[:j].
+func lcsPrefix(x, y []string) []int {
+	prev := make([]int, len(y)+1)
+	cur := make([]int, len(y)+1)
+	for i := range x {
+		for j := 1; j <= len(y); j++ {
+			switch {
+			case x[i] == y[j-1]:
+				cur[j] = prev[j-1] + 1
+			case prev[j] >= cur[j-1]:
+				cur[j] = prev[j]
+			default:
+				cur[j] = cur[j-1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev
+}
+
+// lcsSuffix returns, for every j, the LCS length of x and y[j:].
+func lcsSuffix(x, y []string) []int {
+	prev := make([]int, len(y)+1)
+	cur := make([]int, len(y)+1)
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			switch {
+			case x[i] == y[j]:
+				cur[j] = prev[j+1] + 1
+			case prev[j] >= cur[j+1]:
+				cur[j] = prev[j]
+			default:
+				cur[j] = cur[j+1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev
+}
+
+func appendOp(lines []Line, op string, text []string) []Line {
+	for _, t := range text {
+		lines = append(lines, Line{op, t})
+	}
+
+	return lines
+}
+
+func index(lines []string, s string) int {
+	for i, l := range lines {
+		if l == s {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n")
+}
@@ -0,0 +1,108 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLines(t *testing.T) {
+	testCases := []struct {
+		name string
+		a    string
+		b    string
+		want []Line
+	}{
+		{
+			name: "both empty",
+			a:    "",
+			b:    "",
+			want: []Line{},
+		},
+		{
+			name: "identical",
+			a:    "one\ntwo",
+			b:    "one\ntwo",
+			want: []Line{{OpEqual, "one"}, {OpEqual, "two"}},
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "one\ntwo",
+			want: []Line{{OpInsert, "one"}, {OpInsert, "two"}},
+		},
+		{
+			name: "to empty",
+			a:    "one\ntwo",
+			b:    "",
+			want: []Line{{OpDelete, "one"}, {OpDelete, "two"}},
+		},
+		{
+			name: "pure insert",
+			a:    "one\nthree",
+			b:    "one\ntwo\nthree",
+			want: []Line{{OpEqual, "one"}, {OpInsert, "two"}, {OpEqual, "three"}},
+		},
+		{
+			name: "pure delete",
+			a:    "one\ntwo\nthree",
+			b:    "one\nthree",
+			want: []Line{{OpEqual, "one"}, {OpDelete, "two"}, {OpEqual, "three"}},
+		},
+		{
+			name: "replace",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: []Line{{OpEqual, "one"}, {OpDelete, "two"}, {OpInsert, "2"}, {OpEqual, "three"}},
+		},
+		{
+			name: "crlf",
+			a:    "one\r\ntwo",
+			b:    "one\ntwo",
+			want: []Line{{OpEqual, "one"}, {OpEqual, "two"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Lines(tc.a, tc.b))
+		})
+	}
+}
+
+func TestLines_Minimal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	words := []string{"a", "b", "c", "d"}
+	text := func() string {
+		lines := make([]string, 1+rnd.Intn(30))
+		for i := range lines {
+			lines[i] = words[rnd.Intn(len(words))]
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	for i := 0; i < 200; i++ {
+		a, b := text(), text()
+		lines := Lines(a, b)
+
+		var from, to []string
+		equal := 0
+		for _, l := range lines {
+			if l.Op != OpInsert {
+				from = append(from, l.Text)
+			}
+			if l.Op != OpDelete {
+				to = append(to, l.Text)
+			}
+			if l.Op == OpEqual {
+				equal++
+			}
+		}
+
+		assert.Equal(t, a, strings.Join(from, "\n"))
+		assert.Equal(t, b, strings.Join(to, "\n"))
+		assert.Equal(t, lcsPrefix(split(a), split(b))[len(split(b))], equal, "%q -> %q keeps fewer lines than the LCS", a, b)
+	}
+}
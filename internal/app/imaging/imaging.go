@@ -0,0 +1,144 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	// Register the remaining decoder accepted for uploads.
+	_ "image/gif"
+)
+
+// MaxPixels is the largest width x height Decode accepts. A small compressed
+// file can declare huge dimensions, and decoding allocates for all of them.
+const MaxPixels = 40 << 20
+
+var (
+	// ErrUnsupportedFormat ...
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	// ErrTooLarge ...
+	ErrTooLarge = errors.New("image dimensions are too large")
+)
+
+// Decode reads an image and reports its format. Only gif, jpeg and png are
+// accepted, and only up to MaxPixels.
+func Decode(r io.Reader) (image.Image, string, error) {
+	// The header is read twice: once to check the size, then to decode.
+	header := &bytes.Buffer{}
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, header))
+	if err == image.ErrFormat {
+		return nil, "", ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, "", ErrTooLarge
+	}
+
+	img, format, err := image.Decode(io.MultiReader(header, r))
+	if err == image.ErrFormat {
+		return nil, "", ErrUnsupportedFormat
+	}
+
+	return img, format, err
+}
+
+// EncodePNG ...
+func EncodePNG(w io.Writer, img image.Image) error {
+	return png.Encode(w, img)
+}
+
+// EncodeJPEG ...
+func EncodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+}
+
+// Square crops the centre of img to a square and scales it to size x size.
+func Square(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	return scale(img, image.Rect(x0, y0, x0+side, y0+side), size, size)
+}
+
+// Fit scales img down so that it fits into maxW x maxH, keeping the aspect ratio.
+// Images that already fit are returned unscaled.
+func Fit(img image.Image, maxW, maxH int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxW && h <= maxH {
+		return scale(img, b, w, h)
+	}
+
+	if w*maxH > h*maxW {
+		h = h * maxW / w
+		w = maxW
+	} else {
+		w = w * maxH / h
+		h = maxH
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	return scale(img, b, w, h)
+}
+
+// scale resamples the src rectangle of img into a new w x h image,
+// averaging every source pixel that falls into a destination pixel.
+func scale(img image.Image, src image.Rectangle, w, h int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	sw, sh := src.Dx(), src.Dy()
+
+	for y := 0; y < h; y++ {
+		sy0 := src.Min.Y + y*sh/h
+		sy1 := src.Min.Y + (y+1)*sh/h
+		if sy1 == sy0 {
+			sy1 = sy0 + 1
+		}
+
+		for x := 0; x < w; x++ {
+			sx0 := src.Min.X + x*sw/w
+			sx1 := src.Min.X + (x+1)*sw/w
+			if sx1 == sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					c := color.NRGBAModel.Convert(img.At(sx, sy)).(color.NRGBA)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					b += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / n),
+				G: uint8(g / n),
+				B: uint8(b / n),
+				A: uint8(a / n),
+			})
+		}
+	}
+
+	return dst
+}
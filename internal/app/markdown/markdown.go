@@ -0,0 +1,45 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	md = goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			highlighting.NewHighlighting(
+				highlighting.WithStyle("github"),
+				highlighting.WithFormatOptions(html.WithClasses(true)),
+			),
+		),
+	)
+	policy = newPolicy()
+)
+
+// Render converts Markdown source into sanitized HTML.
+func Render(src string) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := md.Convert([]byte(src), buf); err != nil {
+		return "", err
+	}
+
+	return policy.Sanitize(buf.String()), nil
+}
+
+// newPolicy allows the usual user-content markup plus the class names
+// emitted by the syntax highlighter, and forces rel="nofollow" on links.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	p.AllowAttrs("class").Matching(bluemonday.SpaceSeparatedTokens).OnElements("pre", "code", "span")
+
+	return p
+}
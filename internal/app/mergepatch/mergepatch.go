@@ -0,0 +1,51 @@
+package mergepatch
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Apply applies an RFC 7396 JSON Merge Patch to doc and returns the result.
+func Apply(doc []byte, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := decode(doc, &target); err != nil {
+		return nil, err
+	}
+
+	var p interface{}
+	if err := decode(patch, &p); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+			continue
+		}
+
+		t[name] = merge(t[name], value)
+	}
+
+	return t
+}
+
+// decode keeps numbers as written so large integers survive the round trip.
+func decode(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
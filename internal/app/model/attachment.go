@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Attachment is an uploaded file, optionally referenced by a post.
+type Attachment struct {
+	ID          int       `json:"id"`
+	OwnerID     int       `json:"owner_id"`
+	PostID      *int      `json:"post_id,omitempty"`
+	Key         string    `json:"-"`
+	ThumbKey    string    `json:"-"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	URL         string    `json:"url"`
+	ThumbURL    string    `json:"thumb_url,omitempty"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// AuditEntry records one action taken through the admin API.
+type AuditEntry struct {
+	ID         int                    `json:"id"`
+	ActorID    int                    `json:"actor_id"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"target_type"`
+	TargetID   int                    `json:"target_id"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// SystemStats ...
+type SystemStats struct {
+	Users        int `json:"users"`
+	BannedUsers  int `json:"banned_users"`
+	Posts        int `json:"posts"`
+	HiddenPosts  int `json:"hidden_posts"`
+	DeletedPosts int `json:"deleted_posts"`
+	Stars        int `json:"stars"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// Collection ...
+type Collection struct {
+	ID        int       `json:"id"`
+	Owner     *User     `json:"owner"`
+	Name      string    `json:"name"`
+	IsPublic  bool      `json:"is_public"`
+	Slug      string    `json:"slug,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Posts     []Post    `json:"posts,omitempty"`
+}
+
+// Validate ...
+func (c *Collection) Validate() error {
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.Name, validation.Required, validation.Length(1, 100)),
+	)
+}
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// Follow ...
+type Follow struct {
+	Follower  *User     `json:"follower"`
+	Followee  *User     `json:"followee"`
+	CreatedAt time.Time `json:"created_at"`
+}
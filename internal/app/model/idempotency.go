@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// IdempotencyKey is the response to the first request a user made with a key,
+// replayed when the request is retried. Status is zero while that first
+// request is still being handled.
+type IdempotencyKey struct {
+	UserID      int
+	Key         string
+	RequestHash string
+	Status      int
+	Headers     map[string]string
+	Body        []byte
+	CreatedAt   time.Time
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// Notification types ...
+const (
+	NotificationStar           = "star"
+	NotificationComment        = "comment"
+	NotificationFollow         = "follow"
+	NotificationMention        = "mention"
+	NotificationReportResolved = "report_resolved"
+)
+
+// NotificationTypes lists every type a user can turn on or off.
+var NotificationTypes = []string{
+	NotificationStar,
+	NotificationComment,
+	NotificationFollow,
+	NotificationMention,
+	NotificationReportResolved,
+}
+
+// Notification ...
+type Notification struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Actor     *User      `json:"actor,omitempty"`
+	Type      string     `json:"type"`
+	PostID    *int       `json:"post_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
+
+// IsNotificationType ...
+func IsNotificationType(t string) bool {
+	for _, nt := range NotificationTypes {
+		if nt == t {
+			return true
+		}
+	}
+
+	return false
+}
@@ -6,15 +6,33 @@ import (
 	validation "github.com/go-ozzo/ozzo-validation"
 )
 
+// Post statuses ...
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
+)
+
 // Post ...
 type Post struct {
-	ID         int       `json:"id"`
-	Author     *User     `json:"author"`
-	Header     string    `json:"header"`
-	TextPost   string    `json:"text_post"`
-	CreatedAt  time.Time `json:"created_at"`
-	StarsCount int       `json:"stars_count"`
-	IsStarred  bool      `json:"is_starred"`
+	ID           int          `json:"id"`
+	Author       *User        `json:"author"`
+	Slug         string       `json:"slug"`
+	Version      int          `json:"version"`
+	Header       string       `json:"header"`
+	TextPost     string       `json:"text_post"`
+	TextHTML     string       `json:"text_html"`
+	CreatedAt    time.Time    `json:"created_at"`
+	Status       string       `json:"status"`
+	PublishAt    *time.Time   `json:"publish_at,omitempty"`
+	EditedAt     *time.Time   `json:"edited_at,omitempty"`
+	DeletedAt    *time.Time   `json:"deleted_at,omitempty"`
+	HiddenAt     *time.Time   `json:"hidden_at,omitempty"`
+	StarsCount   int          `json:"stars_count"`
+	IsStarred    bool         `json:"is_starred"`
+	IsBookmarked bool         `json:"is_bookmarked"`
+	Attachments  []Attachment `json:"attachments,omitempty"`
 }
 
 // Validate ...
@@ -23,5 +41,17 @@ func (p *Post) Validate() error {
 		p,
 		validation.Field(&p.Header, validation.Required, validation.Length(16, 256)),
 		validation.Field(&p.TextPost, validation.Required, validation.Length(100, 20000)),
+		validation.Field(&p.Status, validation.Required, validation.In(
+			PostStatusDraft,
+			PostStatusScheduled,
+			PostStatusPublished,
+			PostStatusArchived,
+		)),
+		validation.Field(&p.PublishAt, validation.By(requiredIf(p.Status == PostStatusScheduled))),
 	)
 }
+
+// IsPublished ...
+func (p *Post) IsPublished() bool {
+	return p.Status == PostStatusPublished
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// Report reasons ...
+const (
+	ReportReasonSpam           = "spam"
+	ReportReasonHarassment     = "harassment"
+	ReportReasonHate           = "hate"
+	ReportReasonViolence       = "violence"
+	ReportReasonSexual         = "sexual"
+	ReportReasonMisinformation = "misinformation"
+	ReportReasonOther          = "other"
+)
+
+// Report statuses ...
+const (
+	ReportStatusOpen     = "open"
+	ReportStatusResolved = "resolved"
+)
+
+// Report resolutions ...
+const (
+	ReportResolutionDismiss   = "dismiss"
+	ReportResolutionHide      = "hide"
+	ReportResolutionDelete    = "delete"
+	ReportResolutionBanAuthor = "ban_author"
+)
+
+// Report ...
+type Report struct {
+	ID         int        `json:"id"`
+	PostID     int        `json:"post_id"`
+	ReporterID int        `json:"reporter_id"`
+	Reason     string     `json:"reason"`
+	Comment    string     `json:"comment,omitempty"`
+	Status     string     `json:"status"`
+	Resolution string     `json:"resolution,omitempty"`
+	ResolvedBy *int       `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Validate ...
+func (r *Report) Validate() error {
+	return validation.ValidateStruct(
+		r,
+		validation.Field(&r.Reason, validation.Required, validation.In(
+			ReportReasonSpam,
+			ReportReasonHarassment,
+			ReportReasonHate,
+			ReportReasonViolence,
+			ReportReasonSexual,
+			ReportReasonMisinformation,
+			ReportReasonOther,
+		)),
+		validation.Field(&r.Comment, validation.Length(0, 1000)),
+	)
+}
+
+// ValidResolution ...
+func ValidResolution(resolution string) error {
+	return validation.Validate(resolution, validation.Required, validation.In(
+		ReportResolutionDismiss,
+		ReportResolutionHide,
+		ReportResolutionDelete,
+		ReportResolutionBanAuthor,
+	))
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// Revision is the content a post had before one of its edits.
+type Revision struct {
+	ID        int       `json:"id"`
+	PostID    int       `json:"post_id"`
+	Editor    *User     `json:"editor"`
+	Header    string    `json:"header"`
+	TextPost  string    `json:"text_post,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
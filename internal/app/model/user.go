@@ -1,18 +1,44 @@
 package model
 
 import (
+	"time"
+
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/go-ozzo/ozzo-validation/is"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// User roles ...
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 // User ...
 type User struct {
-	ID                int    `json:"id"`
-	Username          string `json:"username"`
-	Email             string `json:"email"`
-	Password          string `json:"password,omitempty"`
-	EncryptedPassword string `json:"-"`
+	ID                int               `json:"id"`
+	Username          string            `json:"username"`
+	Email             string            `json:"email"`
+	Password          string            `json:"password,omitempty"`
+	EncryptedPassword string            `json:"-"`
+	DisplayName       string            `json:"display_name"`
+	Bio               string            `json:"bio"`
+	Website           string            `json:"website"`
+	Location          string            `json:"location"`
+	Avatar            string            `json:"-"`
+	Role              string            `json:"role"`
+	BannedAt          *time.Time        `json:"banned_at,omitempty"`
+	AvatarURLs        map[string]string `json:"avatar_urls,omitempty"`
+	Stats             *UserStats        `json:"stats,omitempty"`
+}
+
+// UserStats ...
+type UserStats struct {
+	PostsCount     int `json:"posts_count"`
+	StarsReceived  int `json:"stars_received"`
+	FollowersCount int `json:"followers_count"`
+	FollowingCount int `json:"following_count"`
 }
 
 // Validate ...
@@ -25,6 +51,46 @@ func (u *User) Validate() error {
 	)
 }
 
+// ValidateProfile ...
+func (u *User) ValidateProfile() error {
+	return validation.ValidateStruct(
+		u,
+		validation.Field(&u.DisplayName, validation.Length(0, 50)),
+		validation.Field(&u.Bio, validation.Length(0, 500)),
+		validation.Field(&u.Website, is.URL, validation.Length(0, 200)),
+		validation.Field(&u.Location, validation.Length(0, 100)),
+	)
+}
+
+// ValidateRole ...
+func (u *User) ValidateRole() error {
+	return validation.ValidateStruct(
+		u,
+		validation.Field(&u.Role, validation.Required, validation.In(RoleUser, RoleModerator, RoleAdmin)),
+	)
+}
+
+// HasRole reports whether the user has at least the given role.
+func (u *User) HasRole(role string) bool {
+	return roleRank(u.Role) >= roleRank(role)
+}
+
+// IsBanned ...
+func (u *User) IsBanned() bool {
+	return u.BannedAt != nil
+}
+
+func roleRank(role string) int {
+	switch role {
+	case RoleAdmin:
+		return 2
+	case RoleModerator:
+		return 1
+	default:
+		return 0
+	}
+}
+
 // BeforeCreate ...
 func (u *User) BeforeCreate() error {
 	if len(u.Password) > 0 {
@@ -0,0 +1,102 @@
+package slug
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const maxLength = 80
+
+// translit covers the scripts our authors actually write in. Latin letters
+// with diacritics are handled by decomposition instead.
+var translit = map[rune]string{
+	// Russian
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	// Ukrainian and Belarusian
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u",
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	// Latin letters that don't decompose
+	'ß': "ss", 'æ': "ae", 'ø': "o", 'œ': "oe", 'ł': "l", 'đ': "d", 'þ': "th",
+}
+
+// Make turns a header into a lowercase, hyphen-separated ASCII slug.
+// The result is never empty and never purely numeric, so it can't be confused with an ID.
+func Make(s string) string {
+	b := &strings.Builder{}
+	dash := false
+
+	// Composing first keeps combining marks from reading as separators.
+	for _, r := range norm.NFC.String(strings.ToLower(s)) {
+		part, ok := translit[r]
+		if !ok {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				dash = b.Len() > 0
+				continue
+			}
+			part = ascii(r)
+		}
+
+		if part == "" {
+			continue
+		}
+
+		if dash {
+			b.WriteByte('-')
+			dash = false
+		}
+		b.WriteString(part)
+	}
+
+	out := b.String()
+	if len(out) > maxLength {
+		out = strings.TrimRight(out[:maxLength], "-")
+	}
+
+	switch {
+	case out == "":
+		return "post"
+	case isNumeric(out):
+		return "post-" + out
+	}
+
+	return out
+}
+
+// ascii strips diacritics from r, transliterating what's left, so accented
+// Greek and Cyrillic letters work too. Letters from scripts we can't
+// transliterate are dropped rather than mangled.
+func ascii(r rune) string {
+	b := &strings.Builder{}
+	for _, d := range norm.NFD.String(string(r)) {
+		if part, ok := translit[d]; ok {
+			b.WriteString(part)
+			continue
+		}
+
+		if d < unicode.MaxASCII && (unicode.IsLetter(d) || unicode.IsDigit(d)) {
+			b.WriteRune(d)
+		}
+	}
+
+	return b.String()
+}
+
+func isNumeric(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
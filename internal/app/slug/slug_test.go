@@ -0,0 +1,99 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{
+			name:   "latin",
+			header: "Hello, World!",
+			want:   "hello-world",
+		},
+		{
+			name:   "diacritics",
+			header: "Crème brûlée à la française",
+			want:   "creme-brulee-a-la-francaise",
+		},
+		{
+			name:   "russian",
+			header: "Привет, мир",
+			want:   "privet-mir",
+		},
+		{
+			name:   "multi-letter transliterations",
+			header: "Щука и ёж",
+			want:   "shchuka-i-yozh",
+		},
+		{
+			name:   "ukrainian",
+			header: "Їжак і ґанок",
+			want:   "yizhak-i-ganok",
+		},
+		{
+			name:   "decomposed",
+			header: "Cafe\u0301 и\u0306од",
+			want:   "cafe-yod",
+		},
+		{
+			name:   "greek",
+			header: "Ψάρι",
+			want:   "psari",
+		},
+		{
+			name:   "non-decomposing latin",
+			header: "Straße",
+			want:   "strasse",
+		},
+		{
+			name:   "separators collapse",
+			header: "  one --- two  ",
+			want:   "one-two",
+		},
+		{
+			name:   "untransliterated script",
+			header: "東京",
+			want:   "post",
+		},
+		{
+			name:   "empty",
+			header: "",
+			want:   "post",
+		},
+		{
+			name:   "numeric",
+			header: "2021",
+			want:   "post-2021",
+		},
+		{
+			name:   "numeric with separators",
+			header: "20 21",
+			want:   "20-21",
+		},
+		{
+			name:   "numeric after dropped letters",
+			header: "東京 42",
+			want:   "post-42",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Make(tc.header))
+		})
+	}
+}
+
+func TestMake_Length(t *testing.T) {
+	s := Make(strings.Repeat("word ", 40))
+	assert.LessOrEqual(t, len(s), maxLength)
+	assert.False(t, strings.HasSuffix(s, "-"))
+}
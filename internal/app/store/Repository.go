@@ -1,6 +1,10 @@
 package store
 
-import "github.com/zlyaptica/http-rest-api/internal/app/model"
+import (
+	"time"
+
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
 
 // UserRepository ...
 type UserRepository interface {
@@ -8,22 +12,123 @@ type UserRepository interface {
 	Find(int) (*model.User, error)
 	FindByEmail(string) (*model.User, error)
 	FindByID(int) (*model.User, error)
+	FindByUsernames([]string) ([]model.User, error)
+	UpdateProfile(*model.User) error
+	UpdateAvatar(int, string) error
+	Stats(int) (*model.UserStats, error)
+	ContentCount(int) (int, error)
+	Search(string, int, int) ([]model.User, error)
+	SetBanned(int, bool) error
+	SetRole(*model.User) error
+}
+
+// FollowRepository ...
+type FollowRepository interface {
+	Create(*model.Follow) (bool, error)
+	Delete(int, int) error
+	IsFollowing(int, int) (bool, error)
+	Followers(int) ([]model.User, error)
+	Following(int) ([]model.User, error)
 }
 
 // PostRepository ...
 type PostRepository interface {
-	Create(*model.Post) error
-	Delete(int) error
-	Update(string, string, int) error
+	Create(*model.Post, []int) error
+	Delete(int, int, int) error
+	Restore(int, int, time.Time) error
+	FindTrash(int, time.Time) ([]model.Post, error)
+	FindPurgeable(time.Time) ([]int, error)
+	Purge(int) error
+	SetHidden(int, bool, int) error
+	HideReported(int) error
+	Update(*model.Post, int, []int) error
 	FindByAuthor(int) ([]model.Post, error)
 	Find(int) (*model.Post, error)
-	FindAll() ([]model.Post, error)
+	FindBySlug(string) (*model.Post, error)
+	FindIDByOldSlug(string) (int, error)
+	FindAll(int, int) ([]model.Post, error)
+	FindFeed(int, int, int) ([]model.Post, error)
+	FindByCollection(int) ([]model.Post, error)
+	FindOwn(int, string) ([]model.Post, error)
+	UpdateStatus(*model.Post, int) error
+	PublishDue(time.Time) ([]int, error)
+	RenderMissingHTML(int, int) ([]int, error)
 	FindN(int, int) ([]model.Post, error)
 	IsStarredByUser(int, int) (bool, error)
 	GetStarsCount(int) (int, error)
 }
 
+// StarRepository ...
 type StarRepository interface {
 	Create(*model.Star) error
 	Delete(int, int) error
+	FindByStarer(int) ([]model.Star, error)
+}
+
+// CollectionRepository ...
+type CollectionRepository interface {
+	Create(*model.Collection) error
+	Update(*model.Collection) error
+	Delete(int) error
+	Find(int) (*model.Collection, error)
+	FindBySlug(string) (*model.Collection, error)
+	FindByOwner(int) ([]model.Collection, error)
+	AddPost(int, int) error
+	RemovePost(int, int) error
+	PostIDs(int) ([]int, error)
+	Reorder(int, []int) error
+	IsBookmarked(int, int) (bool, error)
+}
+
+// RevisionRepository ...
+type RevisionRepository interface {
+	Find(int) (*model.Revision, error)
+	FindByPost(int) ([]model.Revision, error)
+}
+
+// AttachmentRepository ...
+type AttachmentRepository interface {
+	Create(*model.Attachment) error
+	Find(int) (*model.Attachment, error)
+	FindByPost(int) ([]model.Attachment, error)
+	FindOrphans(time.Time) ([]model.Attachment, error)
+	Delete(int) error
+}
+
+// AuditRepository ...
+type AuditRepository interface {
+	Create(*model.AuditEntry) error
+	FindAll(int, int) ([]model.AuditEntry, error)
+	SystemStats() (*model.SystemStats, error)
+}
+
+// ReportRepository ...
+type ReportRepository interface {
+	Create(*model.Report) (bool, error)
+	Find(int) (*model.Report, error)
+	FindOpen(int, int) ([]model.Report, error)
+	FindByReporter(int) ([]model.Report, error)
+	CountOpen(int) (int, error)
+	Resolve(int, int, string) ([]model.Report, error)
+}
+
+// IdempotencyRepository ...
+type IdempotencyRepository interface {
+	Reserve(*model.IdempotencyKey, time.Time) (bool, error)
+	Complete(*model.IdempotencyKey) error
+	Find(int, string) (*model.IdempotencyKey, error)
+	Delete(int, string) error
+	DeleteExpired(time.Time) (int64, error)
+}
+
+// NotificationRepository ...
+type NotificationRepository interface {
+	Create(*model.Notification) error
+	FindByUser(int, bool, int, int) ([]model.Notification, error)
+	CountUnread(int) (int, error)
+	HasUnread(*model.Notification) (bool, error)
+	MarkRead(int, []int) (int, error)
+	Preferences(int) (map[string]bool, error)
+	SetPreference(int, string, bool) error
+	IsEnabled(int, string) (bool, error)
 }
@@ -5,4 +5,6 @@ import "errors"
 var (
 	// ErrRecordNotFound ...
 	ErrRecordNotFound = errors.New("record not found")
+	// ErrVersionConflict is returned when a record changed since the version the caller read.
+	ErrVersionConflict = errors.New("record was modified concurrently")
 )
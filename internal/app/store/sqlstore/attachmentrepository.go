@@ -0,0 +1,131 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+	"github.com/zlyaptica/http-rest-api/internal/app/store"
+)
+
+const selectAttachments = "SELECT id, owner_id, post_id, blob_key, thumb_key, content_type, size, width, height, created_at FROM attachments"
+
+// AttachmentRepository ...
+type AttachmentRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *AttachmentRepository) Create(a *model.Attachment) error {
+	return r.store.db.QueryRow(
+		"INSERT INTO attachments (owner_id, blob_key, thumb_key, content_type, size, width, height) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at",
+		a.OwnerID,
+		a.Key,
+		a.ThumbKey,
+		a.ContentType,
+		a.Size,
+		a.Width,
+		a.Height,
+	).Scan(&a.ID, &a.CreatedAt)
+}
+
+// Find ...
+func (r *AttachmentRepository) Find(id int) (*model.Attachment, error) {
+	a, err := scanAttachment(r.store.db.QueryRow(selectAttachments+" WHERE id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// FindByPost ...
+func (r *AttachmentRepository) FindByPost(postID int) ([]model.Attachment, error) {
+	return r.findAttachments(selectAttachments+" WHERE post_id = $1 ORDER BY id", postID)
+}
+
+// FindOrphans returns attachments no post references that are older than the given time.
+func (r *AttachmentRepository) FindOrphans(before time.Time) ([]model.Attachment, error) {
+	return r.findAttachments(selectAttachments+" WHERE post_id IS NULL AND created_at < $1 ORDER BY id", before)
+}
+
+// Delete ...
+func (r *AttachmentRepository) Delete(id int) error {
+	_, err := r.store.db.Exec("DELETE FROM attachments WHERE id = $1", id)
+	return err
+}
+
+// setAttachments makes the owner's attachments listed in ids the post's
+// attachment set. Attachments dropped from the set become orphans; those
+// owned by someone else, or used by another post, are left alone.
+func setAttachments(tx *sql.Tx, postID int, ownerID int, ids []int) error {
+	if _, err := tx.Exec(
+		"UPDATE attachments SET post_id = NULL WHERE post_id = $1 AND NOT (id = ANY($2))",
+		postID,
+		pq.Array(ids),
+	); err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(
+		"UPDATE attachments SET post_id = $1 WHERE owner_id = $2 AND id = ANY($3) AND post_id IS NULL",
+		postID,
+		ownerID,
+		pq.Array(ids),
+	)
+	return err
+}
+
+func (r *AttachmentRepository) findAttachments(query string, args ...interface{}) ([]model.Attachment, error) {
+	attachments := []model.Attachment{}
+	rows, err := r.store.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *a)
+	}
+
+	return attachments, rows.Err()
+}
+
+func scanAttachment(row scanner) (*model.Attachment, error) {
+	var postID sql.NullInt64
+	a := &model.Attachment{}
+	if err := row.Scan(
+		&a.ID,
+		&a.OwnerID,
+		&postID,
+		&a.Key,
+		&a.ThumbKey,
+		&a.ContentType,
+		&a.Size,
+		&a.Width,
+		&a.Height,
+		&a.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if postID.Valid {
+		id := int(postID.Int64)
+		a.PostID = &id
+	}
+
+	return a, nil
+}
@@ -0,0 +1,88 @@
+package sqlstore
+
+import (
+	"encoding/json"
+
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+// AuditRepository ...
+type AuditRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *AuditRepository) Create(e *model.AuditEntry) error {
+	details, err := json.Marshal(e.Details)
+	if err != nil {
+		return err
+	}
+	if e.Details == nil {
+		details = []byte("{}")
+	}
+
+	return r.store.db.QueryRow(
+		"INSERT INTO audit_log (actor_id, action, target_type, target_id, details) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at",
+		e.ActorID,
+		e.Action,
+		e.TargetType,
+		e.TargetID,
+		details,
+	).Scan(&e.ID, &e.CreatedAt)
+}
+
+// FindAll ...
+func (r *AuditRepository) FindAll(limit int, offset int) ([]model.AuditEntry, error) {
+	entries := []model.AuditEntry{}
+	rows, err := r.store.db.Query(
+		"SELECT id, actor_id, action, target_type, target_id, details, created_at FROM audit_log ORDER BY id DESC LIMIT $1 OFFSET $2",
+		nullLimit(limit),
+		offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var details []byte
+		e := model.AuditEntry{}
+		if err := rows.Scan(
+			&e.ID,
+			&e.ActorID,
+			&e.Action,
+			&e.TargetType,
+			&e.TargetID,
+			&details,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(details, &e.Details); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// SystemStats ...
+func (r *AuditRepository) SystemStats() (*model.SystemStats, error) {
+	st := &model.SystemStats{}
+	if err := r.store.db.QueryRow(
+		"SELECT (SELECT COUNT(*) FROM users), (SELECT COUNT(*) FROM users WHERE banned_at IS NOT NULL), (SELECT COUNT(*) FROM posts WHERE deleted_at IS NULL), (SELECT COUNT(*) FROM posts WHERE hidden_at IS NOT NULL AND deleted_at IS NULL), (SELECT COUNT(*) FROM posts WHERE deleted_at IS NOT NULL), (SELECT COUNT(*) FROM stars)",
+	).Scan(
+		&st.Users,
+		&st.BannedUsers,
+		&st.Posts,
+		&st.HiddenPosts,
+		&st.DeletedPosts,
+		&st.Stars,
+	); err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
@@ -0,0 +1,207 @@
+package sqlstore
+
+import (
+	"database/sql"
+
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+	"github.com/zlyaptica/http-rest-api/internal/app/store"
+)
+
+// CollectionRepository ...
+type CollectionRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *CollectionRepository) Create(c *model.Collection) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	return r.store.db.QueryRow(
+		"INSERT INTO collections (owner_id, name, is_public, slug) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		c.Owner.ID,
+		c.Name,
+		c.IsPublic,
+		nullString(c.Slug),
+	).Scan(&c.ID, &c.CreatedAt)
+}
+
+// Update ...
+func (r *CollectionRepository) Update(c *model.Collection) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	res, err := r.store.db.Exec(
+		"UPDATE collections SET (name, is_public, slug) = ($1, $2, $3) WHERE id = $4",
+		c.Name,
+		c.IsPublic,
+		nullString(c.Slug),
+		c.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// Delete ...
+func (r *CollectionRepository) Delete(id int) error {
+	res, err := r.store.db.Exec("DELETE FROM collections WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// Find ...
+func (r *CollectionRepository) Find(id int) (*model.Collection, error) {
+	return r.findOne("SELECT id, owner_id, name, is_public, slug, created_at FROM collections WHERE id = $1", id)
+}
+
+// FindBySlug ...
+func (r *CollectionRepository) FindBySlug(slug string) (*model.Collection, error) {
+	return r.findOne("SELECT id, owner_id, name, is_public, slug, created_at FROM collections WHERE slug = $1 AND is_public", slug)
+}
+
+// FindByOwner ...
+func (r *CollectionRepository) FindByOwner(ownerID int) ([]model.Collection, error) {
+	collections := []model.Collection{}
+	rows, err := r.store.db.Query(
+		"SELECT id, owner_id, name, is_public, slug, created_at FROM collections WHERE owner_id = $1 ORDER BY id",
+		ownerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, *c)
+	}
+
+	return collections, rows.Err()
+}
+
+// AddPost appends the post to the end of the collection. Adding it twice is a no-op.
+func (r *CollectionRepository) AddPost(collectionID int, postID int) error {
+	_, err := r.store.db.Exec(
+		"INSERT INTO collection_posts (collection_id, post_id, position) SELECT $1, $2, COALESCE(MAX(position), -1) + 1 FROM collection_posts WHERE collection_id = $1 ON CONFLICT (collection_id, post_id) DO NOTHING",
+		collectionID,
+		postID,
+	)
+	return err
+}
+
+// RemovePost ...
+func (r *CollectionRepository) RemovePost(collectionID int, postID int) error {
+	res, err := r.store.db.Exec(
+		"DELETE FROM collection_posts WHERE collection_id = $1 AND post_id = $2",
+		collectionID,
+		postID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// PostIDs ...
+func (r *CollectionRepository) PostIDs(collectionID int) ([]int, error) {
+	ids := []int{}
+	if err := r.store.db.Select(
+		&ids,
+		"SELECT post_id FROM collection_posts WHERE collection_id = $1 ORDER BY position",
+		collectionID,
+	); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// Reorder sets the position of every post to its index in postIDs.
+func (r *CollectionRepository) Reorder(collectionID int, postIDs []int) error {
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, postID := range postIDs {
+		if _, err := tx.Exec(
+			"UPDATE collection_posts SET position = $1 WHERE collection_id = $2 AND post_id = $3",
+			i,
+			collectionID,
+			postID,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// IsBookmarked ...
+func (r *CollectionRepository) IsBookmarked(userID int, postID int) (bool, error) {
+	var count int
+	if err := r.store.db.QueryRow(
+		"SELECT COUNT(*) FROM collection_posts INNER JOIN collections ON collections.id = collection_posts.collection_id WHERE collections.owner_id = $1 AND collection_posts.post_id = $2",
+		userID,
+		postID,
+	).Scan(&count); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (r *CollectionRepository) findOne(query string, arg interface{}) (*model.Collection, error) {
+	c, err := scanCollection(r.store.db.QueryRow(query, arg))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return c, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCollection(row scanner) (*model.Collection, error) {
+	var slug sql.NullString
+	c := &model.Collection{
+		Owner: &model.User{},
+	}
+	if err := row.Scan(
+		&c.ID,
+		&c.Owner.ID,
+		&c.Name,
+		&c.IsPublic,
+		&slug,
+		&c.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	c.Slug = slug.String
+
+	return c, nil
+}
+
+// nullString stores empty strings as NULL so they don't collide on unique columns.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
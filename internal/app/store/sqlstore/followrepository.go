@@ -0,0 +1,89 @@
+package sqlstore
+
+import (
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+// FollowRepository ...
+type FollowRepository struct {
+	store *Store
+}
+
+// Create follows the followee, or leaves an existing follow as it is, and
+// reports whether the follow is new.
+func (r *FollowRepository) Create(f *model.Follow) (bool, error) {
+	var created bool
+	if err := r.store.db.QueryRow(
+		"INSERT INTO follows (follower_id, followee_id) VALUES ($1, $2) ON CONFLICT (follower_id, followee_id) DO UPDATE SET follower_id = EXCLUDED.follower_id RETURNING created_at, xmax = 0",
+		f.Follower.ID,
+		f.Followee.ID,
+	).Scan(&f.CreatedAt, &created); err != nil {
+		return false, err
+	}
+
+	return created, nil
+}
+
+// Delete ...
+func (r *FollowRepository) Delete(followerID int, followeeID int) error {
+	_, err := r.store.db.Exec(
+		"DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2",
+		followerID,
+		followeeID,
+	)
+	return err
+}
+
+// IsFollowing ...
+func (r *FollowRepository) IsFollowing(followerID int, followeeID int) (bool, error) {
+	var count int
+	if err := r.store.db.QueryRow(
+		"SELECT COUNT(*) FROM follows WHERE follower_id = $1 AND followee_id = $2",
+		followerID,
+		followeeID,
+	).Scan(&count); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// Followers ...
+func (r *FollowRepository) Followers(userID int) ([]model.User, error) {
+	return r.findUsers(
+		"SELECT users.id, users.username, users.display_name, users.avatar FROM follows INNER JOIN users ON users.id = follows.follower_id WHERE follows.followee_id = $1 ORDER BY follows.created_at DESC",
+		userID,
+	)
+}
+
+// Following ...
+func (r *FollowRepository) Following(userID int) ([]model.User, error) {
+	return r.findUsers(
+		"SELECT users.id, users.username, users.display_name, users.avatar FROM follows INNER JOIN users ON users.id = follows.followee_id WHERE follows.follower_id = $1 ORDER BY follows.created_at DESC",
+		userID,
+	)
+}
+
+func (r *FollowRepository) findUsers(query string, userID int) ([]model.User, error) {
+	users := []model.User{}
+	rows, err := r.store.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u := model.User{}
+		if err := rows.Scan(
+			&u.ID,
+			&u.Username,
+			&u.DisplayName,
+			&u.Avatar,
+		); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
@@ -0,0 +1,104 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+	"github.com/zlyaptica/http-rest-api/internal/app/store"
+)
+
+// IdempotencyRepository ...
+type IdempotencyRepository struct {
+	store *Store
+}
+
+// Reserve claims the key for a new request. It reports false when the key is
+// already held by a request made after expiredBefore; an older holder is replaced.
+func (r *IdempotencyRepository) Reserve(k *model.IdempotencyKey, expiredBefore time.Time) (bool, error) {
+	err := r.store.db.QueryRow(
+		"INSERT INTO idempotency_keys (user_id, key, request_hash) VALUES ($1, $2, $3) ON CONFLICT (user_id, key) DO UPDATE SET (request_hash, status, headers, body, created_at) = (EXCLUDED.request_hash, 0, '{}', NULL, now()) WHERE idempotency_keys.created_at < $4 RETURNING created_at",
+		k.UserID,
+		k.Key,
+		k.RequestHash,
+		expiredBefore,
+	).Scan(&k.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Complete stores the response to the request that reserved the key.
+func (r *IdempotencyRepository) Complete(k *model.IdempotencyKey) error {
+	headers, err := json.Marshal(k.Headers)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.store.db.Exec(
+		"UPDATE idempotency_keys SET (status, headers, body) = ($1, $2, $3) WHERE user_id = $4 AND key = $5",
+		k.Status,
+		headers,
+		k.Body,
+		k.UserID,
+		k.Key,
+	)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// Find ...
+func (r *IdempotencyRepository) Find(userID int, key string) (*model.IdempotencyKey, error) {
+	k := &model.IdempotencyKey{}
+	var headers []byte
+	if err := r.store.db.QueryRow(
+		"SELECT user_id, key, request_hash, status, headers, body, created_at FROM idempotency_keys WHERE user_id = $1 AND key = $2",
+		userID,
+		key,
+	).Scan(
+		&k.UserID,
+		&k.Key,
+		&k.RequestHash,
+		&k.Status,
+		&headers,
+		&k.Body,
+		&k.CreatedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	if err := json.Unmarshal(headers, &k.Headers); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// Delete releases the key so the request can be retried from scratch.
+func (r *IdempotencyRepository) Delete(userID int, key string) error {
+	_, err := r.store.db.Exec("DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2", userID, key)
+	return err
+}
+
+// DeleteExpired removes keys created before the given time and returns how many there were.
+func (r *IdempotencyRepository) DeleteExpired(before time.Time) (int64, error) {
+	res, err := r.store.db.Exec("DELETE FROM idempotency_keys WHERE created_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
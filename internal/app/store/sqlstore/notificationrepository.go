@@ -0,0 +1,194 @@
+package sqlstore
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+)
+
+// NotificationRepository ...
+type NotificationRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *NotificationRepository) Create(n *model.Notification) error {
+	var actorID *int
+	if n.Actor != nil {
+		actorID = &n.Actor.ID
+	}
+
+	return r.store.db.QueryRow(
+		"INSERT INTO notifications (user_id, actor_id, type, post_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		n.UserID,
+		actorID,
+		n.Type,
+		n.PostID,
+	).Scan(&n.ID, &n.CreatedAt)
+}
+
+// FindByUser ...
+func (r *NotificationRepository) FindByUser(userID int, unreadOnly bool, limit int, offset int) ([]model.Notification, error) {
+	notifications := []model.Notification{}
+	rows, err := r.store.db.Query(
+		"SELECT notifications.id, notifications.user_id, users.id, users.username, notifications.type, notifications.post_id, notifications.created_at, notifications.read_at FROM notifications LEFT JOIN users ON users.id = notifications.actor_id WHERE notifications.user_id = $1 AND (NOT $2 OR notifications.read_at IS NULL) ORDER BY notifications.id DESC LIMIT $3 OFFSET $4",
+		userID,
+		unreadOnly,
+		nullLimit(limit),
+		offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			actorID       sql.NullInt64
+			actorUsername sql.NullString
+			postID        sql.NullInt64
+		)
+		n := model.Notification{}
+		if err := rows.Scan(
+			&n.ID,
+			&n.UserID,
+			&actorID,
+			&actorUsername,
+			&n.Type,
+			&postID,
+			&n.CreatedAt,
+			&n.ReadAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if actorID.Valid {
+			n.Actor = &model.User{ID: int(actorID.Int64), Username: actorUsername.String}
+		}
+		if postID.Valid {
+			id := int(postID.Int64)
+			n.PostID = &id
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// CountUnread ...
+func (r *NotificationRepository) CountUnread(userID int) (int, error) {
+	var count int
+	if err := r.store.db.QueryRow(
+		"SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL",
+		userID,
+	).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// HasUnread reports whether the user has an unread notification like n: of
+// the same type, from the same actor and about the same post.
+func (r *NotificationRepository) HasUnread(n *model.Notification) (bool, error) {
+	var actorID *int
+	if n.Actor != nil {
+		actorID = &n.Actor.ID
+	}
+
+	var exists bool
+	if err := r.store.db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM notifications WHERE user_id = $1 AND actor_id IS NOT DISTINCT FROM $2 AND type = $3 AND post_id IS NOT DISTINCT FROM $4 AND read_at IS NULL)",
+		n.UserID,
+		actorID,
+		n.Type,
+		n.PostID,
+	).Scan(&exists); err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// MarkRead marks the user's notifications with the given IDs as read, or all of them when ids is empty.
+func (r *NotificationRepository) MarkRead(userID int, ids []int) (int, error) {
+	var (
+		res sql.Result
+		err error
+	)
+	if len(ids) == 0 {
+		res, err = r.store.db.Exec(
+			"UPDATE notifications SET read_at = now() WHERE user_id = $1 AND read_at IS NULL",
+			userID,
+		)
+	} else {
+		res, err = r.store.db.Exec(
+			"UPDATE notifications SET read_at = now() WHERE user_id = $1 AND read_at IS NULL AND id = ANY($2)",
+			userID,
+			pq.Array(ids),
+		)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Preferences returns whether each notification type is enabled. Types the user never set are enabled.
+func (r *NotificationRepository) Preferences(userID int) (map[string]bool, error) {
+	prefs := make(map[string]bool, len(model.NotificationTypes))
+	for _, t := range model.NotificationTypes {
+		prefs[t] = true
+	}
+
+	rows, err := r.store.db.Query(
+		"SELECT type, enabled FROM notification_preferences WHERE user_id = $1",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			t       string
+			enabled bool
+		)
+		if err := rows.Scan(&t, &enabled); err != nil {
+			return nil, err
+		}
+		prefs[t] = enabled
+	}
+
+	return prefs, rows.Err()
+}
+
+// SetPreference ...
+func (r *NotificationRepository) SetPreference(userID int, notificationType string, enabled bool) error {
+	_, err := r.store.db.Exec(
+		"INSERT INTO notification_preferences (user_id, type, enabled) VALUES ($1, $2, $3) ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled",
+		userID,
+		notificationType,
+		enabled,
+	)
+	return err
+}
+
+// IsEnabled ...
+func (r *NotificationRepository) IsEnabled(userID int, notificationType string) (bool, error) {
+	enabled := true
+	err := r.store.db.QueryRow(
+		"SELECT enabled FROM notification_preferences WHERE user_id = $1 AND type = $2",
+		userID,
+		notificationType,
+	).Scan(&enabled)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+
+	return enabled, nil
+}
@@ -2,45 +2,369 @@ package sqlstore
 
 import (
 	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/zlyaptica/http-rest-api/internal/app/markdown"
 	"github.com/zlyaptica/http-rest-api/internal/app/model"
+	"github.com/zlyaptica/http-rest-api/internal/app/slug"
 	"github.com/zlyaptica/http-rest-api/internal/app/store"
 )
 
+const selectPosts = "SELECT users.username, users.id, posts.id, posts.slug, posts.version, posts.header, posts.text_post, posts.text_html, posts.created_at, posts.status, posts.publish_at, posts.edited_at, posts.deleted_at, posts.hidden_at FROM posts INNER JOIN users ON posts.author_id = users.id"
+
 // PostRepository ...
 type PostRepository struct {
 	store *Store
 }
 
-// Create ...
-func (r *PostRepository) Create(p *model.Post) error {
+// Create inserts the post and attaches the author's uploads listed in attachmentIDs.
+func (r *PostRepository) Create(p *model.Post, attachmentIDs []int) error {
 	if err := p.Validate(); err != nil {
 		return err
 	}
 
-	return r.store.db.QueryRow(
-		"INSERT INTO posts (author_id, header, text_post, created_at) VALUES ($1, $2, $3, $4) RETURNING id",
+	if err := renderHTML(p); err != nil {
+		return err
+	}
+
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	p.Slug, err = uniqueSlug(tx, p.Header, 0)
+	if err != nil {
+		return err
+	}
+
+	p.CreatedAt = time.Now()
+	p.Version = 1
+	if err := tx.QueryRow(
+		"INSERT INTO posts (author_id, slug, header, text_post, text_html, created_at, status, publish_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
 		p.Author.ID,
+		p.Slug,
 		p.Header,
 		p.TextPost,
-		time.Now(),
-	).Scan(&p.ID)
+		p.TextHTML,
+		p.CreatedAt,
+		p.Status,
+		p.PublishAt,
+	).Scan(&p.ID); err != nil {
+		return err
+	}
+
+	if err := setAttachments(tx, p.ID, p.Author.ID, attachmentIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// Delete ...
-func (r *PostRepository) Delete(id int) error {
-	_, err := r.store.db.Query("DELETE FROM stars WHERE post_id = $1", id)
-	_, err = r.store.db.Query("DELETE FROM posts WHERE id = $1", id)
-	return err
+// Delete moves the post to the trash on behalf of deletedBy. Posts their
+// author deleted stay restorable until they're purged; posts removed by
+// someone else, such as a moderator, don't. Unless version is 0, it fails
+// with store.ErrVersionConflict when the stored version differs.
+func (r *PostRepository) Delete(id int, deletedBy int, version int) error {
+	res, err := r.store.db.Exec(
+		"UPDATE posts SET (deleted_at, deleted_by, version) = (now(), $2, version + 1) WHERE id = $1 AND deleted_at IS NULL AND ($3 = 0 OR version = $3)",
+		id,
+		deletedBy,
+		version,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := requireAffected(res); err != store.ErrRecordNotFound {
+		return err
+	}
+
+	return r.missingOrConflict(id, version)
+}
+
+// missingOrConflict explains why a write conditioned on version touched no
+// rows: if the post is still there, its version moved on.
+func (r *PostRepository) missingOrConflict(id int, version int) error {
+	if version == 0 {
+		return store.ErrRecordNotFound
+	}
+
+	var exists bool
+	if err := r.store.db.QueryRow("SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return store.ErrVersionConflict
+	}
+
+	return store.ErrRecordNotFound
+}
+
+// Restore takes the author's post out of the trash if they deleted it after the given time.
+func (r *PostRepository) Restore(id int, authorID int, deletedAfter time.Time) error {
+	res, err := r.store.db.Exec(
+		"UPDATE posts SET (deleted_at, deleted_by, version) = (NULL, NULL, version + 1) WHERE id = $1 AND author_id = $2 AND deleted_by = author_id AND deleted_at > $3",
+		id,
+		authorID,
+		deletedAfter,
+	)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
 }
 
-// Update ...
-func (r *PostRepository) Update(header string, text_post string, id int) error {
-	_, err := r.store.db.Query("UPDATE posts SET (header, text_post) = ($1, $2) WHERE id = $3", header, text_post, id)
+// SetHidden hides a post from public listings, or shows it again. Unless
+// version is 0, it fails with store.ErrVersionConflict when the stored
+// version differs.
+func (r *PostRepository) SetHidden(id int, hidden bool, version int) error {
+	query := "UPDATE posts SET (hidden_at, hidden_by_reports, version) = (NULL, false, version + 1) WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)"
+	if hidden {
+		query = "UPDATE posts SET (hidden_at, hidden_by_reports, version) = (COALESCE(hidden_at, now()), false, version + 1) WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)"
+	}
+
+	res, err := r.store.db.Exec(query, id, version)
+	if err != nil {
+		return err
+	}
+
+	if err := requireAffected(res); err != store.ErrRecordNotFound {
+		return err
+	}
+
+	return r.missingOrConflict(id, version)
+}
+
+// HideReported hides a post that has drawn too many reports, remembering the
+// hide was automatic so dismissing the reports can undo it. Posts that are
+// already hidden are left as they are.
+func (r *PostRepository) HideReported(id int) error {
+	_, err := r.store.db.Exec(
+		"UPDATE posts SET (hidden_at, hidden_by_reports, version) = (now(), true, version + 1) WHERE id = $1 AND hidden_at IS NULL AND deleted_at IS NULL",
+		id,
+	)
 	return err
 }
 
+// FindTrash returns the posts the author deleted after the given time.
+func (r *PostRepository) FindTrash(authorID int, deletedAfter time.Time) ([]model.Post, error) {
+	return r.findPosts(
+		selectPosts+" WHERE users.id = $1 AND posts.deleted_by = posts.author_id AND posts.deleted_at > $2 ORDER BY posts.deleted_at DESC",
+		authorID,
+		deletedAfter,
+	)
+}
+
+// FindPurgeable returns the IDs of posts deleted before the given time.
+func (r *PostRepository) FindPurgeable(deletedBefore time.Time) ([]int, error) {
+	ids := []int{}
+	if err := r.store.db.Select(
+		&ids,
+		"SELECT id FROM posts WHERE deleted_at <= $1 ORDER BY id",
+		deletedBefore,
+	); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// Purge permanently removes a trashed post and its stars.
+func (r *PostRepository) Purge(id int) error {
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM stars WHERE post_id = $1", id); err != nil {
+		return err
+	}
+
+	res, err := tx.Exec("DELETE FROM posts WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(res); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update saves the post's current content as a revision by editorID, then overwrites it.
+// Unless attachmentIDs is nil, the author's uploads it lists become the post's attachments.
+// It fails with store.ErrVersionConflict unless p.Version is still the stored version,
+// which it then increments.
+func (r *PostRepository) Update(p *model.Post, editorID int, attachmentIDs []int) error {
+	if err := renderHTML(p); err != nil {
+		return err
+	}
+
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		"INSERT INTO post_revisions (post_id, editor_id, header, text_post) SELECT id, $1, header, text_post FROM posts WHERE id = $2",
+		editorID,
+		p.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(res); err != nil {
+		return err
+	}
+
+	// Header edits move the post to a new slug; the old one is kept so links to it can redirect.
+	var oldSlug string
+	var version int
+	if err := tx.QueryRow("SELECT slug, version FROM posts WHERE id = $1 FOR UPDATE", p.ID).Scan(&oldSlug, &version); err != nil {
+		return err
+	}
+
+	if version != p.Version {
+		return store.ErrVersionConflict
+	}
+
+	p.Slug = oldSlug
+	if !hasSlugBase(oldSlug, slug.Make(p.Header)) {
+		p.Slug, err = uniqueSlug(tx, p.Header, p.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.Slug != oldSlug {
+		if _, err := tx.Exec(
+			"INSERT INTO post_slugs (slug, post_id) VALUES ($1, $2) ON CONFLICT (slug) DO NOTHING",
+			oldSlug,
+			p.ID,
+		); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM post_slugs WHERE slug = $1 AND post_id = $2", p.Slug, p.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.QueryRow(
+		"UPDATE posts SET (slug, header, text_post, text_html, edited_at, version) = ($1, $2, $3, $4, now(), version + 1) WHERE id = $5 RETURNING edited_at, version",
+		p.Slug,
+		p.Header,
+		p.TextPost,
+		p.TextHTML,
+		p.ID,
+	).Scan(&p.EditedAt, &p.Version); err != nil {
+		return err
+	}
+
+	if attachmentIDs != nil {
+		if err := setAttachments(tx, p.ID, p.Author.ID, attachmentIDs); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateStatus saves the post's status and publish time. Unless version is 0,
+// it fails with store.ErrVersionConflict when the stored version differs.
+func (r *PostRepository) UpdateStatus(p *model.Post, version int) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	if err := r.store.db.QueryRow(
+		"UPDATE posts SET (status, publish_at, version) = ($1, $2, version + 1) WHERE id = $3 AND deleted_at IS NULL AND ($4 = 0 OR version = $4) RETURNING version",
+		p.Status,
+		p.PublishAt,
+		p.ID,
+		version,
+	).Scan(&p.Version); err != nil {
+		if err == sql.ErrNoRows {
+			return r.missingOrConflict(p.ID, version)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// PublishDue publishes every scheduled post whose publish time has passed and returns their IDs.
+func (r *PostRepository) PublishDue(now time.Time) ([]int, error) {
+	ids := []int{}
+	rows, err := r.store.db.Query(
+		"UPDATE posts SET status = 'published', version = version + 1 WHERE status = 'scheduled' AND publish_at <= $1 AND deleted_at IS NULL RETURNING id",
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// RenderMissingHTML renders and stores the HTML of up to limit posts after
+// afterID that were written before rendering existed, and returns their IDs.
+func (r *PostRepository) RenderMissingHTML(afterID int, limit int) ([]int, error) {
+	rows, err := r.store.db.Query(
+		"SELECT id, text_post FROM posts WHERE id > $1 AND text_html = '' AND text_post <> '' ORDER BY id LIMIT $2",
+		afterID,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := []*model.Post{}
+	for rows.Next() {
+		p := &model.Post{}
+		if err := rows.Scan(&p.ID, &p.TextPost); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := []int{}
+	for _, p := range posts {
+		if err := renderHTML(p); err != nil {
+			return ids, err
+		}
+
+		if _, err := r.store.db.Exec("UPDATE posts SET text_html = $1 WHERE id = $2 AND text_html = ''", p.TextHTML, p.ID); err != nil {
+			return ids, err
+		}
+		ids = append(ids, p.ID)
+	}
+
+	return ids, nil
+}
+
 // IsStarredByUser ...
 func (r *PostRepository) IsStarredByUser(userID int, postID int) (bool, error) {
 	var count int
@@ -73,21 +397,8 @@ func (r *PostRepository) GetStarsCount(postID int) (int, error) {
 
 // Find ...
 func (r *PostRepository) Find(id int) (*model.Post, error) {
-	u := &model.User{}
-	p := &model.Post{
-		Author: u,
-	}
-	if err := r.store.db.QueryRow(
-		"SELECT users.username, users.id, posts.id, posts.header, posts.text_post, posts.created_at FROM posts INNER JOIN users ON posts.author_id = users.id WHERE posts.id = $1",
-		id,
-	).Scan(
-		&p.Author.Username,
-		&p.Author.ID,
-		&p.ID,
-		&p.Header,
-		&p.TextPost,
-		&p.CreatedAt,
-	); err != nil {
+	p, err := scanPost(r.store.db.QueryRow(selectPosts+" WHERE posts.id = $1 AND posts.deleted_at IS NULL", id))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, store.ErrRecordNotFound
 		}
@@ -98,85 +409,203 @@ func (r *PostRepository) Find(id int) (*model.Post, error) {
 	return p, nil
 }
 
-// FindAll ...
-func (r *PostRepository) FindAll() ([]model.Post, error) {
-	posts := []model.Post{}
-	rows, err := r.store.db.Query(
-		"SELECT users.username, users.id, posts.id, posts.header, posts.text_post, posts.created_at FROM posts INNER JOIN users ON posts.author_id = users.id ORDER BY posts.id DESC",
-	)
-	defer rows.Close()
-
+// FindBySlug ...
+func (r *PostRepository) FindBySlug(slug string) (*model.Post, error) {
+	p, err := scanPost(r.store.db.QueryRow(selectPosts+" WHERE posts.slug = $1 AND posts.deleted_at IS NULL", slug))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, store.ErrRecordNotFound
 		}
+
 		return nil, err
 	}
 
-	for rows.Next() {
-		u := &model.User{}
-		p := model.Post{
-			Author: u,
-		}
-		err := rows.Scan(
-			&p.Author.Username,
-			&p.Author.ID,
-			&p.ID,
-			&p.Header,
-			&p.TextPost,
-			&p.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
+	return p, nil
+}
 
-		p.StarsCount, err = r.GetStarsCount(p.ID)
+// FindIDByOldSlug looks up the post a slug used to point to before its header was edited.
+func (r *PostRepository) FindIDByOldSlug(slug string) (int, error) {
+	var id int
+	if err := r.store.db.QueryRow("SELECT post_id FROM post_slugs WHERE slug = $1", slug).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, store.ErrRecordNotFound
+		}
 
-		posts = append(posts, p)
+		return 0, err
 	}
 
-	return posts, nil
+	return id, nil
+}
+
+// FindAll ...
+func (r *PostRepository) FindAll(limit int, offset int) ([]model.Post, error) {
+	return r.findPosts(
+		selectPosts+" WHERE posts.status = 'published' AND posts.deleted_at IS NULL AND posts.hidden_at IS NULL ORDER BY posts.id DESC LIMIT $1 OFFSET $2",
+		nullLimit(limit),
+		offset,
+	)
 }
 
 // FindByAuthor ...
 func (r *PostRepository) FindByAuthor(id int) ([]model.Post, error) {
-	posts := []model.Post{}
-	rows, err := r.store.db.Query(
-		"SELECT users.username, users.id, posts.id, posts.header, posts.text_post, posts.created_at FROM posts INNER JOIN users ON posts.author_id = users.id WHERE users.id = $1 ORDER BY posts.id DESC",
+	return r.findPosts(
+		selectPosts+" WHERE users.id = $1 AND posts.status = 'published' AND posts.deleted_at IS NULL AND posts.hidden_at IS NULL ORDER BY posts.id DESC",
 		id,
 	)
-	defer rows.Close()
+}
+
+// FindOwn returns the author's posts in every status, optionally narrowed to one.
+func (r *PostRepository) FindOwn(authorID int, status string) ([]model.Post, error) {
+	if status == "" {
+		return r.findPosts(selectPosts+" WHERE users.id = $1 AND posts.deleted_at IS NULL ORDER BY posts.id DESC", authorID)
+	}
+
+	return r.findPosts(selectPosts+" WHERE users.id = $1 AND posts.status = $2 AND posts.deleted_at IS NULL ORDER BY posts.id DESC", authorID, status)
+}
+
+// FindFeed ...
+func (r *PostRepository) FindFeed(followerID int, limit int, offset int) ([]model.Post, error) {
+	return r.findPosts(
+		selectPosts+" INNER JOIN follows ON follows.followee_id = posts.author_id WHERE follows.follower_id = $1 AND posts.status = 'published' AND posts.deleted_at IS NULL AND posts.hidden_at IS NULL ORDER BY posts.id DESC LIMIT $2 OFFSET $3",
+		followerID,
+		nullLimit(limit),
+		offset,
+	)
+}
 
+// FindByCollection ...
+func (r *PostRepository) FindByCollection(collectionID int) ([]model.Post, error) {
+	return r.findPosts(
+		selectPosts+" INNER JOIN collection_posts ON collection_posts.post_id = posts.id WHERE collection_posts.collection_id = $1 AND posts.status = 'published' AND posts.deleted_at IS NULL AND posts.hidden_at IS NULL ORDER BY collection_posts.position",
+		collectionID,
+	)
+}
+
+func (r *PostRepository) findPosts(query string, args ...interface{}) ([]model.Post, error) {
+	posts := []model.Post{}
+	rows, err := r.store.db.Query(query, args...)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, store.ErrRecordNotFound
-		}
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		u := &model.User{}
-		p := model.Post{
-			Author: u,
-		}
-		err := rows.Scan(
-			&p.Author.Username,
-			&p.Author.ID,
-			&p.ID,
-			&p.Header,
-			&p.TextPost,
-			&p.CreatedAt,
-		)
+		p, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range posts {
+		posts[i].StarsCount, err = r.GetStarsCount(posts[i].ID)
 		if err != nil {
 			return nil, err
 		}
-		p.StarsCount, err = r.GetStarsCount(p.ID)
-		posts = append(posts, p)
 	}
 
 	return posts, nil
 }
 
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// uniqueSlug derives a slug from header that no other post uses or used to use,
+// appending -2, -3, ... as needed.
+func uniqueSlug(q queryRower, header string, postID int) (string, error) {
+	base := slug.Make(header)
+	candidate := base
+	for i := 2; ; i++ {
+		var taken bool
+		if err := q.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM posts WHERE slug = $1 AND id <> $2) OR EXISTS (SELECT 1 FROM post_slugs WHERE slug = $1 AND post_id <> $2)",
+			candidate,
+			postID,
+		).Scan(&taken); err != nil {
+			return "", err
+		}
+
+		if !taken {
+			return candidate, nil
+		}
+
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// hasSlugBase reports whether s is base itself or base with a numeric suffix added by uniqueSlug.
+func hasSlugBase(s string, base string) bool {
+	if s == base {
+		return true
+	}
+
+	suffix := strings.TrimPrefix(s, base+"-")
+	if suffix == s || suffix == "" {
+		return false
+	}
+
+	_, err := strconv.Atoi(suffix)
+	return err == nil
+}
+
+func scanPost(row scanner) (*model.Post, error) {
+	p := &model.Post{
+		Author: &model.User{},
+	}
+	if err := row.Scan(
+		&p.Author.Username,
+		&p.Author.ID,
+		&p.ID,
+		&p.Slug,
+		&p.Version,
+		&p.Header,
+		&p.TextPost,
+		&p.TextHTML,
+		&p.CreatedAt,
+		&p.Status,
+		&p.PublishAt,
+		&p.EditedAt,
+		&p.DeletedAt,
+		&p.HiddenAt,
+	); err != nil {
+		return nil, err
+	}
+
+	// Posts written before rendering existed keep empty HTML until
+	// RenderMissingHTML gets to them.
+	if p.TextHTML == "" && p.TextPost != "" {
+		if err := renderHTML(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// renderHTML renders the post's Markdown into TextHTML.
+func renderHTML(p *model.Post) error {
+	html, err := markdown.Render(p.TextPost)
+	if err != nil {
+		return err
+	}
+
+	p.TextHTML = html
+	return nil
+}
+
+// nullLimit maps a zero limit to NULL, which Postgres treats as LIMIT ALL.
+func nullLimit(limit int) interface{} {
+	if limit <= 0 {
+		return nil
+	}
+
+	return limit
+}
+
 // FindN ...
 func (r *PostRepository) FindN(id int, n int) ([]model.Post, error) {
 	p := model.Post{}
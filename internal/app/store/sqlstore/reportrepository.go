@@ -0,0 +1,193 @@
+package sqlstore
+
+import (
+	"database/sql"
+
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+	"github.com/zlyaptica/http-rest-api/internal/app/store"
+)
+
+const selectReports = "SELECT id, post_id, reporter_id, reason, comment, status, resolution, resolved_by, resolved_at, created_at FROM reports"
+
+// ReportRepository ...
+type ReportRepository struct {
+	store *Store
+}
+
+// Create files a report. If the reporter's earlier report on the post is
+// still open, it's loaded into rep instead and created is false; once it's
+// resolved they can report the post again.
+func (r *ReportRepository) Create(rep *model.Report) (bool, error) {
+	if err := rep.Validate(); err != nil {
+		return false, err
+	}
+
+	err := r.store.db.QueryRow(
+		"INSERT INTO reports (post_id, reporter_id, reason, comment) VALUES ($1, $2, $3, $4) ON CONFLICT (post_id, reporter_id) WHERE status = 'open' DO NOTHING RETURNING id, status, created_at",
+		rep.PostID,
+		rep.ReporterID,
+		rep.Reason,
+		rep.Comment,
+	).Scan(&rep.ID, &rep.Status, &rep.CreatedAt)
+	if err == nil {
+		return true, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+
+	existing, err := scanReport(r.store.db.QueryRow(
+		selectReports+" WHERE post_id = $1 AND reporter_id = $2 AND status = 'open'",
+		rep.PostID,
+		rep.ReporterID,
+	))
+	if err != nil {
+		return false, err
+	}
+	*rep = *existing
+
+	return false, nil
+}
+
+// Find ...
+func (r *ReportRepository) Find(id int) (*model.Report, error) {
+	rep, err := scanReport(r.store.db.QueryRow(selectReports+" WHERE id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return rep, nil
+}
+
+// FindOpen returns the moderation queue, oldest first.
+func (r *ReportRepository) FindOpen(limit int, offset int) ([]model.Report, error) {
+	return r.findReports(selectReports+" WHERE status = 'open' ORDER BY created_at LIMIT $1 OFFSET $2", nullLimit(limit), offset)
+}
+
+// FindByReporter ...
+func (r *ReportRepository) FindByReporter(reporterID int) ([]model.Report, error) {
+	return r.findReports(selectReports+" WHERE reporter_id = $1 ORDER BY id DESC", reporterID)
+}
+
+// CountOpen ...
+func (r *ReportRepository) CountOpen(postID int) (int, error) {
+	var count int
+	if err := r.store.db.QueryRow(
+		"SELECT COUNT(*) FROM reports WHERE post_id = $1 AND status = 'open'",
+		postID,
+	).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Resolve closes every open report on the post and applies the resolution in
+// the same transaction, then returns the closed reports. If another resolver
+// got there first, nothing is applied and no reports are returned.
+func (r *ReportRepository) Resolve(postID int, resolverID int, resolution string) ([]model.Report, error) {
+	if err := model.ValidResolution(resolution); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		"UPDATE reports SET (status, resolution, resolved_by, resolved_at) = ('resolved', $1, $2, now()) WHERE post_id = $3 AND status = 'open' RETURNING id, post_id, reporter_id, reason, comment, status, resolution, resolved_by, resolved_at, created_at",
+		resolution,
+		resolverID,
+		postID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []model.Report{}
+	for rows.Next() {
+		rep, err := scanReport(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		reports = append(reports, *rep)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(reports) == 0 {
+		return reports, nil
+	}
+
+	// Dismissing only undoes a hide the reports themselves caused. Deleting
+	// is done on the resolver's behalf, so the author can't restore the post.
+	switch resolution {
+	case model.ReportResolutionDismiss:
+		_, err = tx.Exec("UPDATE posts SET (hidden_at, hidden_by_reports, version) = (NULL, false, version + 1) WHERE id = $1 AND hidden_by_reports", postID)
+	case model.ReportResolutionHide:
+		_, err = tx.Exec("UPDATE posts SET (hidden_at, hidden_by_reports, version) = (COALESCE(hidden_at, now()), false, version + 1) WHERE id = $1 AND deleted_at IS NULL", postID)
+	case model.ReportResolutionDelete:
+		_, err = tx.Exec("UPDATE posts SET (deleted_at, deleted_by, version) = (now(), $1, version + 1) WHERE id = $2 AND deleted_at IS NULL", resolverID, postID)
+	case model.ReportResolutionBanAuthor:
+		_, err = tx.Exec("UPDATE users SET banned_at = COALESCE(banned_at, now()) WHERE id = (SELECT author_id FROM posts WHERE id = $1)", postID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return reports, tx.Commit()
+}
+
+func (r *ReportRepository) findReports(query string, args ...interface{}) ([]model.Report, error) {
+	reports := []model.Report{}
+	rows, err := r.store.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rep, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *rep)
+	}
+
+	return reports, rows.Err()
+}
+
+func scanReport(row scanner) (*model.Report, error) {
+	var resolvedBy sql.NullInt64
+	rep := &model.Report{}
+	if err := row.Scan(
+		&rep.ID,
+		&rep.PostID,
+		&rep.ReporterID,
+		&rep.Reason,
+		&rep.Comment,
+		&rep.Status,
+		&rep.Resolution,
+		&resolvedBy,
+		&rep.ResolvedAt,
+		&rep.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if resolvedBy.Valid {
+		id := int(resolvedBy.Int64)
+		rep.ResolvedBy = &id
+	}
+
+	return rep, nil
+}
@@ -0,0 +1,72 @@
+package sqlstore
+
+import (
+	"database/sql"
+
+	"github.com/zlyaptica/http-rest-api/internal/app/model"
+	"github.com/zlyaptica/http-rest-api/internal/app/store"
+)
+
+// RevisionRepository ...
+type RevisionRepository struct {
+	store *Store
+}
+
+// Find ...
+func (r *RevisionRepository) Find(id int) (*model.Revision, error) {
+	rev := &model.Revision{
+		Editor: &model.User{},
+	}
+	if err := r.store.db.QueryRow(
+		"SELECT post_revisions.id, post_revisions.post_id, users.id, users.username, post_revisions.header, post_revisions.text_post, post_revisions.created_at FROM post_revisions INNER JOIN users ON users.id = post_revisions.editor_id WHERE post_revisions.id = $1",
+		id,
+	).Scan(
+		&rev.ID,
+		&rev.PostID,
+		&rev.Editor.ID,
+		&rev.Editor.Username,
+		&rev.Header,
+		&rev.TextPost,
+		&rev.CreatedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return rev, nil
+}
+
+// FindByPost returns the post's revisions, newest first, without their text.
+func (r *RevisionRepository) FindByPost(postID int) ([]model.Revision, error) {
+	revisions := []model.Revision{}
+	rows, err := r.store.db.Query(
+		"SELECT post_revisions.id, post_revisions.post_id, users.id, users.username, post_revisions.header, post_revisions.created_at FROM post_revisions INNER JOIN users ON users.id = post_revisions.editor_id WHERE post_revisions.post_id = $1 ORDER BY post_revisions.id DESC",
+		postID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rev := model.Revision{
+			Editor: &model.User{},
+		}
+		if err := rows.Scan(
+			&rev.ID,
+			&rev.PostID,
+			&rev.Editor.ID,
+			&rev.Editor.Username,
+			&rev.Header,
+			&rev.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
@@ -30,6 +30,36 @@ func (r *StarRepository) Delete(userID int, postID int) error {
 	return err
 }
 
+// FindByStarer ...
+func (r *StarRepository) FindByStarer(userID int) ([]model.Star, error) {
+	stars := []model.Star{}
+	rows, err := r.store.db.Query(
+		"SELECT stars.id, stars.post_id, posts.header FROM stars INNER JOIN posts ON posts.id = stars.post_id WHERE stars.liker_id = $1 ORDER BY stars.id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s := model.Star{
+			Starer: &model.User{ID: userID},
+			Post:   &model.Post{},
+		}
+		if err := rows.Scan(
+			&s.ID,
+			&s.Post.ID,
+			&s.Post.Header,
+		); err != nil {
+			return nil, err
+		}
+		stars = append(stars, s)
+	}
+
+	return stars, rows.Err()
+}
+
 // Find ...
 func (r *PostRepository) FindByPostID(postID int) ([]model.Star, error) {
 	stars := []model.Star{}
@@ -1,16 +1,26 @@
 package sqlstore
 
 import (
+	"database/sql"
+
 	"github.com/jmoiron/sqlx"
 	"github.com/zlyaptica/http-rest-api/internal/app/store"
 )
 
 // Store ...
 type Store struct {
-	db             *sqlx.DB
-	userRepository *UserRepository
-	postRepository *PostRepository
-	starRepository *StarRepository
+	db                     *sqlx.DB
+	userRepository         *UserRepository
+	postRepository         *PostRepository
+	starRepository         *StarRepository
+	followRepository       *FollowRepository
+	collectionRepository   *CollectionRepository
+	revisionRepository     *RevisionRepository
+	attachmentRepository   *AttachmentRepository
+	auditRepository        *AuditRepository
+	reportRepository       *ReportRepository
+	notificationRepository *NotificationRepository
+	idempotencyRepository  *IdempotencyRepository
 }
 
 // New ...
@@ -58,3 +68,121 @@ func (s *Store) Star() store.StarRepository {
 
 	return s.starRepository
 }
+
+// Follow ...
+func (s *Store) Follow() store.FollowRepository {
+	if s.followRepository != nil {
+		return s.followRepository
+	}
+
+	s.followRepository = &FollowRepository{
+		store: s,
+	}
+
+	return s.followRepository
+}
+
+// Collection ...
+func (s *Store) Collection() store.CollectionRepository {
+	if s.collectionRepository != nil {
+		return s.collectionRepository
+	}
+
+	s.collectionRepository = &CollectionRepository{
+		store: s,
+	}
+
+	return s.collectionRepository
+}
+
+// Revision ...
+func (s *Store) Revision() store.RevisionRepository {
+	if s.revisionRepository != nil {
+		return s.revisionRepository
+	}
+
+	s.revisionRepository = &RevisionRepository{
+		store: s,
+	}
+
+	return s.revisionRepository
+}
+
+// Attachment ...
+func (s *Store) Attachment() store.AttachmentRepository {
+	if s.attachmentRepository != nil {
+		return s.attachmentRepository
+	}
+
+	s.attachmentRepository = &AttachmentRepository{
+		store: s,
+	}
+
+	return s.attachmentRepository
+}
+
+// Audit ...
+func (s *Store) Audit() store.AuditRepository {
+	if s.auditRepository != nil {
+		return s.auditRepository
+	}
+
+	s.auditRepository = &AuditRepository{
+		store: s,
+	}
+
+	return s.auditRepository
+}
+
+// Report ...
+func (s *Store) Report() store.ReportRepository {
+	if s.reportRepository != nil {
+		return s.reportRepository
+	}
+
+	s.reportRepository = &ReportRepository{
+		store: s,
+	}
+
+	return s.reportRepository
+}
+
+// Notification ...
+func (s *Store) Notification() store.NotificationRepository {
+	if s.notificationRepository != nil {
+		return s.notificationRepository
+	}
+
+	s.notificationRepository = &NotificationRepository{
+		store: s,
+	}
+
+	return s.notificationRepository
+}
+
+// Idempotency ...
+func (s *Store) Idempotency() store.IdempotencyRepository {
+	if s.idempotencyRepository != nil {
+		return s.idempotencyRepository
+	}
+
+	s.idempotencyRepository = &IdempotencyRepository{
+		store: s,
+	}
+
+	return s.idempotencyRepository
+}
+
+// requireAffected turns an UPDATE or DELETE that matched nothing into store.ErrRecordNotFound.
+func requireAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return store.ErrRecordNotFound
+	}
+
+	return nil
+}
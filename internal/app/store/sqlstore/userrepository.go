@@ -3,6 +3,7 @@ package sqlstore
 import (
 	"database/sql"
 
+	"github.com/lib/pq"
 	"github.com/zlyaptica/http-rest-api/internal/app/model"
 	"github.com/zlyaptica/http-rest-api/internal/app/store"
 )
@@ -34,13 +35,20 @@ func (r *UserRepository) Create(u *model.User) error {
 func (r *UserRepository) Find(id int) (*model.User, error) {
 	u := &model.User{}
 	if err := r.store.db.QueryRow(
-		"SELECT id, email, encrypted_password, username FROM users WHERE id = $1",
+		"SELECT id, email, encrypted_password, username, display_name, bio, website, location, avatar, role, banned_at FROM users WHERE id = $1",
 		id,
 	).Scan(
 		&u.ID,
 		&u.Email,
 		&u.EncryptedPassword,
 		&u.Username,
+		&u.DisplayName,
+		&u.Bio,
+		&u.Website,
+		&u.Location,
+		&u.Avatar,
+		&u.Role,
+		&u.BannedAt,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, store.ErrRecordNotFound
@@ -56,13 +64,20 @@ func (r *UserRepository) Find(id int) (*model.User, error) {
 func (r *UserRepository) FindByEmail(email string) (*model.User, error) {
 	u := &model.User{}
 	if err := r.store.db.QueryRow(
-		"SELECT id, email, encrypted_password, username FROM users WHERE email = $1",
+		"SELECT id, email, encrypted_password, username, display_name, bio, website, location, avatar, role, banned_at FROM users WHERE email = $1",
 		email,
 	).Scan(
 		&u.ID,
 		&u.Email,
 		&u.EncryptedPassword,
 		&u.Username,
+		&u.DisplayName,
+		&u.Bio,
+		&u.Website,
+		&u.Location,
+		&u.Avatar,
+		&u.Role,
+		&u.BannedAt,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, store.ErrRecordNotFound
@@ -78,9 +93,19 @@ func (r *UserRepository) FindByEmail(email string) (*model.User, error) {
 func (r *UserRepository) FindByID(id int) (*model.User, error) {
 	u := &model.User{}
 	if err := r.store.db.QueryRow(
-		"SELECT id, username FROM users WHERE id = $1",
+		"SELECT id, username, display_name, bio, website, location, avatar, role, banned_at FROM users WHERE id = $1",
 		id,
-	).Scan(&u.ID, &u.Username); err != nil {
+	).Scan(
+		&u.ID,
+		&u.Username,
+		&u.DisplayName,
+		&u.Bio,
+		&u.Website,
+		&u.Location,
+		&u.Avatar,
+		&u.Role,
+		&u.BannedAt,
+	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, store.ErrRecordNotFound
 		}
@@ -90,3 +115,154 @@ func (r *UserRepository) FindByID(id int) (*model.User, error) {
 
 	return u, nil
 }
+
+// FindByUsernames ...
+func (r *UserRepository) FindByUsernames(usernames []string) ([]model.User, error) {
+	users := []model.User{}
+	if len(usernames) == 0 {
+		return users, nil
+	}
+
+	rows, err := r.store.db.Query(
+		"SELECT id, username FROM users WHERE username = ANY($1) AND banned_at IS NULL",
+		pq.Array(usernames),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u := model.User{}
+		if err := rows.Scan(&u.ID, &u.Username); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// UpdateProfile ...
+func (r *UserRepository) UpdateProfile(u *model.User) error {
+	if err := u.ValidateProfile(); err != nil {
+		return err
+	}
+
+	res, err := r.store.db.Exec(
+		"UPDATE users SET (display_name, bio, website, location) = ($1, $2, $3, $4) WHERE id = $5",
+		u.DisplayName,
+		u.Bio,
+		u.Website,
+		u.Location,
+		u.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// UpdateAvatar ...
+func (r *UserRepository) UpdateAvatar(id int, avatar string) error {
+	res, err := r.store.db.Exec("UPDATE users SET avatar = $1 WHERE id = $2", avatar, id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// Stats ...
+func (r *UserRepository) Stats(id int) (*model.UserStats, error) {
+	st := &model.UserStats{}
+	if err := r.store.db.QueryRow(
+		"SELECT (SELECT COUNT(*) FROM posts WHERE author_id = $1 AND status = 'published' AND deleted_at IS NULL), (SELECT COUNT(*) FROM stars INNER JOIN posts ON posts.id = stars.post_id WHERE posts.author_id = $1 AND posts.deleted_at IS NULL), (SELECT COUNT(*) FROM follows WHERE followee_id = $1), (SELECT COUNT(*) FROM follows WHERE follower_id = $1)",
+		id,
+	).Scan(
+		&st.PostsCount,
+		&st.StarsReceived,
+		&st.FollowersCount,
+		&st.FollowingCount,
+	); err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// ContentCount returns how many posts and stars the user has made, which is
+// what the size of their export depends on.
+func (r *UserRepository) ContentCount(id int) (int, error) {
+	var n int
+	if err := r.store.db.QueryRow(
+		"SELECT (SELECT COUNT(*) FROM posts WHERE author_id = $1 AND deleted_at IS NULL) + (SELECT COUNT(*) FROM stars WHERE liker_id = $1)",
+		id,
+	).Scan(&n); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// Search finds users whose username, display name or email contains the query.
+func (r *UserRepository) Search(query string, limit int, offset int) ([]model.User, error) {
+	users := []model.User{}
+	rows, err := r.store.db.Query(
+		"SELECT id, email, username, display_name, role, banned_at FROM users WHERE username ILIKE '%' || $1 || '%' OR display_name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%' ORDER BY id LIMIT $2 OFFSET $3",
+		query,
+		nullLimit(limit),
+		offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u := model.User{}
+		if err := rows.Scan(
+			&u.ID,
+			&u.Email,
+			&u.Username,
+			&u.DisplayName,
+			&u.Role,
+			&u.BannedAt,
+		); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// SetBanned ...
+func (r *UserRepository) SetBanned(id int, banned bool) error {
+	query := "UPDATE users SET banned_at = NULL WHERE id = $1"
+	if banned {
+		query = "UPDATE users SET banned_at = COALESCE(banned_at, now()) WHERE id = $1"
+	}
+
+	res, err := r.store.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// SetRole ...
+func (r *UserRepository) SetRole(u *model.User) error {
+	if err := u.ValidateRole(); err != nil {
+		return err
+	}
+
+	res, err := r.store.db.Exec("UPDATE users SET role = $1 WHERE id = $2", u.Role, u.ID)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
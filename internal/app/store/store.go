@@ -5,4 +5,12 @@ type Store interface {
 	User() UserRepository
 	Post() PostRepository
 	Star() StarRepository
+	Follow() FollowRepository
+	Collection() CollectionRepository
+	Revision() RevisionRepository
+	Attachment() AttachmentRepository
+	Audit() AuditRepository
+	Report() ReportRepository
+	Notification() NotificationRepository
+	Idempotency() IdempotencyRepository
 }
@@ -0,0 +1,6 @@
+ALTER TABLE users
+    DROP COLUMN display_name,
+    DROP COLUMN bio,
+    DROP COLUMN website,
+    DROP COLUMN location,
+    DROP COLUMN avatar;
@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN display_name varchar NOT NULL DEFAULT '',
+    ADD COLUMN bio varchar NOT NULL DEFAULT '',
+    ADD COLUMN website varchar NOT NULL DEFAULT '',
+    ADD COLUMN location varchar NOT NULL DEFAULT '',
+    ADD COLUMN avatar varchar NOT NULL DEFAULT '';
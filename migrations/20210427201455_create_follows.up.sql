@@ -0,0 +1,9 @@
+CREATE TABLE follows (
+    follower_id bigint not null REFERENCES users,
+    followee_id bigint not null REFERENCES users,
+    created_at timestamptz not null DEFAULT now(),
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX follows_followee_id_idx ON follows (followee_id);
@@ -0,0 +1,2 @@
+DROP TABLE collection_posts;
+DROP TABLE collections;
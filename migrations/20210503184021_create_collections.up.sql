@@ -0,0 +1,18 @@
+CREATE TABLE collections (
+    id bigserial not null PRIMARY KEY,
+    owner_id bigint not null REFERENCES users,
+    name varchar not null,
+    is_public boolean not null DEFAULT false,
+    slug varchar unique,
+    created_at timestamptz not null DEFAULT now()
+);
+
+CREATE TABLE collection_posts (
+    collection_id bigint not null REFERENCES collections ON DELETE CASCADE,
+    post_id bigint not null REFERENCES posts ON DELETE CASCADE,
+    position integer not null,
+    added_at timestamptz not null DEFAULT now(),
+    PRIMARY KEY (collection_id, post_id)
+);
+
+CREATE INDEX collection_posts_post_id_idx ON collection_posts (post_id);
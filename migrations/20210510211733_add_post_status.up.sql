@@ -0,0 +1,5 @@
+ALTER TABLE posts
+    ADD COLUMN status varchar NOT NULL DEFAULT 'published',
+    ADD COLUMN publish_at timestamptz;
+
+CREATE INDEX posts_scheduled_publish_at_idx ON posts (publish_at) WHERE status = 'scheduled';
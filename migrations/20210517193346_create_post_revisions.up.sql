@@ -0,0 +1,13 @@
+CREATE TABLE post_revisions (
+    id bigserial not null PRIMARY KEY,
+    post_id bigint not null REFERENCES posts ON DELETE CASCADE,
+    editor_id bigint not null REFERENCES users,
+    header varchar not null,
+    text_post varchar not null,
+    created_at timestamptz not null DEFAULT now()
+);
+
+CREATE INDEX post_revisions_post_id_idx ON post_revisions (post_id);
+
+ALTER TABLE posts
+    ADD COLUMN edited_at timestamptz;
@@ -0,0 +1,2 @@
+ALTER TABLE posts
+    ADD COLUMN text_html varchar NOT NULL DEFAULT '';
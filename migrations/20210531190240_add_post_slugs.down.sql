@@ -0,0 +1,4 @@
+DROP TABLE post_slugs;
+
+ALTER TABLE posts
+    DROP COLUMN slug;
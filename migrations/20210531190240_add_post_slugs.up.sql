@@ -0,0 +1,14 @@
+ALTER TABLE posts
+    ADD COLUMN slug varchar;
+
+UPDATE posts SET slug = 'post-' || id;
+
+ALTER TABLE posts
+    ALTER COLUMN slug SET NOT NULL,
+    ADD CONSTRAINT posts_slug_key UNIQUE (slug);
+
+CREATE TABLE post_slugs (
+    slug varchar not null PRIMARY KEY,
+    post_id bigint not null REFERENCES posts ON DELETE CASCADE,
+    created_at timestamptz not null DEFAULT now()
+);
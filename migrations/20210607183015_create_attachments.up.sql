@@ -0,0 +1,15 @@
+CREATE TABLE attachments (
+    id bigserial not null PRIMARY KEY,
+    owner_id bigint not null REFERENCES users,
+    post_id bigint REFERENCES posts ON DELETE SET NULL,
+    blob_key varchar not null,
+    thumb_key varchar not null DEFAULT '',
+    content_type varchar not null,
+    size bigint not null,
+    width integer not null DEFAULT 0,
+    height integer not null DEFAULT 0,
+    created_at timestamptz not null DEFAULT now()
+);
+
+CREATE INDEX attachments_post_id_idx ON attachments (post_id);
+CREATE INDEX attachments_orphans_idx ON attachments (created_at) WHERE post_id IS NULL;
@@ -0,0 +1,4 @@
+DROP INDEX posts_deleted_at_idx;
+
+ALTER TABLE posts
+    DROP COLUMN deleted_at;
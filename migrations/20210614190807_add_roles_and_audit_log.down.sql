@@ -0,0 +1,8 @@
+DROP TABLE audit_log;
+
+ALTER TABLE posts
+    DROP COLUMN hidden_at;
+
+ALTER TABLE users
+    DROP COLUMN role,
+    DROP COLUMN banned_at;
@@ -0,0 +1,16 @@
+ALTER TABLE users
+    ADD COLUMN role varchar NOT NULL DEFAULT 'user',
+    ADD COLUMN banned_at timestamptz;
+
+ALTER TABLE posts
+    ADD COLUMN hidden_at timestamptz;
+
+CREATE TABLE audit_log (
+    id bigserial not null PRIMARY KEY,
+    actor_id bigint not null REFERENCES users,
+    action varchar not null,
+    target_type varchar not null,
+    target_id bigint not null,
+    details jsonb not null DEFAULT '{}',
+    created_at timestamptz not null DEFAULT now()
+);
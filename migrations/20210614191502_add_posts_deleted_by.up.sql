@@ -0,0 +1,2 @@
+ALTER TABLE posts
+    ADD COLUMN deleted_by bigint REFERENCES users;
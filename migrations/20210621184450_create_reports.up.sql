@@ -0,0 +1,15 @@
+CREATE TABLE reports (
+    id bigserial not null PRIMARY KEY,
+    post_id bigint not null REFERENCES posts ON DELETE CASCADE,
+    reporter_id bigint not null REFERENCES users,
+    reason varchar not null,
+    comment varchar not null DEFAULT '',
+    status varchar not null DEFAULT 'open',
+    resolution varchar not null DEFAULT '',
+    resolved_by bigint REFERENCES users,
+    resolved_at timestamptz,
+    created_at timestamptz not null DEFAULT now(),
+    UNIQUE (post_id, reporter_id)
+);
+
+CREATE INDEX reports_open_idx ON reports (created_at) WHERE status = 'open';
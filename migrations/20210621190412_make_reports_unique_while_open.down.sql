@@ -0,0 +1,8 @@
+DROP INDEX reports_open_reporter_idx;
+
+DELETE FROM reports older
+    USING reports newer
+    WHERE older.post_id = newer.post_id AND older.reporter_id = newer.reporter_id AND older.id < newer.id;
+
+ALTER TABLE reports
+    ADD CONSTRAINT reports_post_id_reporter_id_key UNIQUE (post_id, reporter_id);
@@ -0,0 +1,4 @@
+ALTER TABLE reports
+    DROP CONSTRAINT reports_post_id_reporter_id_key;
+
+CREATE UNIQUE INDEX reports_open_reporter_idx ON reports (post_id, reporter_id) WHERE status = 'open';
@@ -0,0 +1,2 @@
+ALTER TABLE posts
+    ADD COLUMN hidden_by_reports boolean NOT NULL DEFAULT false;
@@ -0,0 +1,19 @@
+CREATE TABLE notifications (
+    id bigserial not null PRIMARY KEY,
+    user_id bigint not null REFERENCES users,
+    actor_id bigint REFERENCES users,
+    type varchar not null,
+    post_id bigint REFERENCES posts ON DELETE CASCADE,
+    created_at timestamptz not null DEFAULT now(),
+    read_at timestamptz
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, id DESC);
+CREATE INDEX notifications_unread_idx ON notifications (user_id) WHERE read_at IS NULL;
+
+CREATE TABLE notification_preferences (
+    user_id bigint not null REFERENCES users,
+    type varchar not null,
+    enabled boolean not null,
+    PRIMARY KEY (user_id, type)
+);
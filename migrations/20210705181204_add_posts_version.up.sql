@@ -0,0 +1 @@
+ALTER TABLE posts ADD COLUMN version integer not null DEFAULT 1;
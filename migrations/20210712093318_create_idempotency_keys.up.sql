@@ -0,0 +1,12 @@
+CREATE TABLE idempotency_keys (
+    user_id bigint not null REFERENCES users ON DELETE CASCADE,
+    key varchar not null,
+    request_hash varchar not null,
+    status integer not null DEFAULT 0,
+    headers jsonb not null DEFAULT '{}',
+    body bytea,
+    created_at timestamptz not null DEFAULT now(),
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys (created_at);